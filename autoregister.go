@@ -0,0 +1,115 @@
+package database
+
+import (
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var autoRegister bool
+
+//SetAutoRegister makes writes using the GobCodec register the concrete type of every value found in an interface field
+//with gob the first time it is seen, so that polymorphic values can be written without calling RegisterType first.
+//It is off by default, because it walks every value written, which costs time for large values which contain interfaces.
+//Registration is global and only happens on write, so a process which reads such values without writing one first
+//must still register the types with RegisterType or WithTypes. Registration is safe to run from several goroutines.
+//Types which gob refuses to register, for example because a different type was registered under the same name, are skipped,
+//and the write fails with gob's own error if the type really is unknown.
+//It should be called before any transactions are executed.
+func SetAutoRegister(enabled bool) {
+	autoRegister = enabled
+}
+
+//registered holds a sync.Once for every type registered automatically, keyed by the type pointers to it point to.
+var registered sync.Map
+
+//holdsInterface caches, for each type, whether a value of that type can contain an interface.
+var holdsInterface sync.Map
+
+//registerInterfaces registers with gob the concrete type of every value held in an interface within the given value.
+func registerInterfaces(value interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("database: cannot register type with gob: %v", r)
+		}
+	}()
+	walkInterfaces(reflect.ValueOf(value), make(map[uintptr]bool))
+	return nil
+}
+
+//walkInterfaces registers the concrete types held in interfaces within v, following each pointer only once.
+func walkInterfaces(v reflect.Value, visited map[uintptr]bool) {
+	if !v.IsValid() || !mayHoldInterface(v.Type()) {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		//gob registers a type and pointers to it under one name, so only the first form seen is registered.
+		base := e.Type()
+		for base.Kind() == reflect.Ptr {
+			base = base.Elem()
+		}
+		once, _ := registered.LoadOrStore(base, new(sync.Once))
+		once.(*sync.Once).Do(func() {
+			//gob panics if the type, or another with the same name, is already registered in a different form.
+			//It is left to the encoder to report a type which is really missing.
+			defer func() { recover() }()
+			gob.Register(e.Interface())
+		})
+		walkInterfaces(e, visited)
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return
+		}
+		visited[v.Pointer()] = true
+		walkInterfaces(v.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				walkInterfaces(v.Field(i), visited)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkInterfaces(v.Index(i), visited)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkInterfaces(iter.Key(), visited)
+			walkInterfaces(iter.Value(), visited)
+		}
+	}
+}
+
+//mayHoldInterface reports whether a value of the given type can contain an interface in a field gob encodes,
+//so that values which cannot, such as byte slices, are not walked element by element.
+func mayHoldInterface(t reflect.Type) bool {
+	if cached, ok := holdsInterface.Load(t); ok {
+		return cached.(bool)
+	}
+	//Recursive types are assumed to hold an interface while they are being checked, which is safe, if slower.
+	holdsInterface.Store(t, true)
+	var result bool
+	switch t.Kind() {
+	case reflect.Interface:
+		result = true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		result = mayHoldInterface(t.Elem())
+	case reflect.Map:
+		result = mayHoldInterface(t.Key()) || mayHoldInterface(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && !result; i++ {
+			if t.Field(i).PkgPath == "" {
+				result = mayHoldInterface(t.Field(i).Type)
+			}
+		}
+	}
+	holdsInterface.Store(t, result)
+	return result
+}
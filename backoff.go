@@ -0,0 +1,60 @@
+package database
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	retryBackoffBase = 2 * time.Millisecond
+	retryBackoffMax  = 100 * time.Millisecond
+	retryBudget      time.Duration
+)
+
+//SetRetryBackoff sets how long Execute waits before trying a transaction again.
+//The wait before each retry is chosen at random between zero and base doubled for every earlier retry, up to max,
+//so that transactions which conflicted with each other do not all retry at once. A base of zero retries immediately.
+//It should be called before any transactions are executed.
+func SetRetryBackoff(base, max time.Duration) error {
+	if base < 0 || max < base {
+		return errors.New("database: retry backoff must satisfy 0 <= base <= max")
+	}
+	retryBackoffBase = base
+	retryBackoffMax = max
+	return nil
+}
+
+//SetRetryBudget limits the total time Execute spends on a transaction, including waits between attempts, or zero to remove the limit.
+//Once the budget would be exceeded, Execute gives up with an error matching ErrRetriesExhausted, even if attempts remain.
+//A context with a deadline passed to ExecuteContext has a similar effect, but returns the context's error instead.
+//It should be called before any transactions are executed.
+func SetRetryBudget(budget time.Duration) {
+	retryBudget = budget
+}
+
+//jitter is the random source for backoff, seeded separately in every process so that processes do not wait in step.
+var jitter = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+//backoff returns how long to wait before the given retry, counting the first retry as one.
+func backoff(retry int) time.Duration {
+	if retryBackoffBase <= 0 {
+		return 0
+	}
+	limit := retryBackoffMax
+	if retry < 32 {
+		if d := retryBackoffBase << uint(retry-1); d > 0 && d < limit {
+			limit = d
+		}
+	}
+	if limit <= 0 {
+		return 0
+	}
+	jitter.Lock()
+	defer jitter.Unlock()
+	return time.Duration(jitter.Int63n(int64(limit) + 1))
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+//Export writes every key in the default database which matches the given pattern, along with its stored bytes, to w.
+func Export(w io.Writer, pattern string) error {
+	return db.Export(w, pattern)
+}
+
+//Export is the Database equivalent of the package level Export.
+//Each key is written as its length as a uvarint followed by the key, then the same for its value.
+//Values are exported exactly as stored, without decoding them, so the stream does not depend on the Codec and Import restores them exactly.
+//Keys are read in batches as they are scanned, so the keyspace is never held in memory, but the export is not a consistent snapshot:
+//keys changed during the export may or may not be included. Only plain string values are exported, without their expiry;
+//lists, hashes, sets and sorted sets are skipped. If the Database has a prefix, it is removed from the exported keys.
+func (d *Database) Export(w io.Writer, pattern string) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	bw := bufio.NewWriter(w)
+	var batch []string
+	write := func() error {
+		results, err := client.MGet(context.Background(), d.keys(batch)...).Result()
+		if err != nil {
+			return err
+		}
+		for i, r := range results {
+			data, ok := r.(string)
+			if !ok {
+				continue
+			}
+			if err := writeRecord(bw, batch[i]); err != nil {
+				return err
+			}
+			if err := writeRecord(bw, data); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err = d.ScanKeys(pattern, scanBatchSize, func(key string) error {
+		batch = append(batch, key)
+		if len(batch) < scanBatchSize {
+			return nil
+		}
+		return write()
+	})
+	if err == nil && len(batch) > 0 {
+		err = write()
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+//Import writes every key read from r, in the format written by Export, to the default database.
+func Import(r io.Reader) error {
+	return db.Import(r)
+}
+
+//Import is the Database equivalent of the package level Import.
+//Keys are written in pipelined batches as they are read, replacing any existing values, without a transaction,
+//so if it fails partway the keys before the failure remain written. If the Database has a prefix, it is added to each key.
+func (d *Database) Import(r io.Reader) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	br := bufio.NewReader(r)
+	ctx := context.Background()
+	pipe := client.Pipeline()
+	queued := 0
+	for {
+		key, err := readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data, err := readRecord(br)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		d.track(string(key))
+		pipe.Set(ctx, d.key(string(key)), data, 0)
+		queued++
+		if queued == flushBatchSize {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+			queued = 0
+		}
+	}
+	if queued > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//errBadRecord is returned by Import when a record in the stream is longer than any value redis can store.
+var errBadRecord = errors.New("database: invalid record in import stream")
+
+//maxRecordSize is the largest record Import accepts, which is the largest string redis can store.
+const maxRecordSize = 512 << 20
+
+//writeRecord writes the given string to w, preceded by its length as a uvarint.
+func writeRecord(w *bufio.Writer, s string) error {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(len(s)))
+	if _, err := w.Write(length[:n]); err != nil {
+		return err
+	}
+	_, err := w.WriteString(s)
+	return err
+}
+
+//readRecord reads a record written by writeRecord from r.
+//It returns io.EOF only if r ends before the record starts.
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecordSize {
+		return nil, errBadRecord
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//BatchWriter buffers writes in memory and sends them to redis in pipelined batches, to reduce round trips for high volume writes.
+//Durability is best effort: writes buffered since the last flush are lost if the process exits without calling Close,
+//and a batch is not a transaction, so other processes may see part of one. If the same key is put more than once between flushes, only the last value is written.
+type BatchWriter struct {
+	d       *Database
+	size    int
+	mu      sync.Mutex
+	pending map[string][]byte
+	closed  bool
+	//flushing serializes flushes, so that batches reach redis in the order they were taken.
+	flushing sync.Mutex
+	full     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+//NewBatchWriter creates a BatchWriter for the default database.
+func NewBatchWriter(interval time.Duration, size int) (*BatchWriter, error) {
+	return db.NewBatchWriter(interval, size)
+}
+
+//NewBatchWriter creates a BatchWriter which flushes from a background goroutine every interval, and as soon as size keys are buffered.
+//It must be closed to stop the goroutine and write anything still buffered.
+//It returns ErrClosed if the Database is not connected.
+func (d *Database) NewBatchWriter(interval time.Duration, size int) (*BatchWriter, error) {
+	client, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	d.end(client)
+	if interval <= 0 || size < 1 {
+		return nil, errors.New("database: batch writer interval must be positive and size at least 1")
+	}
+	b := &BatchWriter{
+		d:       d,
+		size:    size,
+		pending: make(map[string][]byte),
+		full:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run(interval)
+	return b, nil
+}
+
+//run flushes the BatchWriter until it is closed.
+func (b *BatchWriter) run(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+		if err := b.Flush(); err != nil {
+			logger.Println("batch writer failed to flush:", err)
+		}
+	}
+}
+
+//Put encodes the given value with the Codec and buffers it to be written at the given key by the next flush.
+//It returns an error if the value cannot be encoded, or if the BatchWriter has been closed.
+func (b *BatchWriter) Put(key string, value interface{}) error {
+	data, err := b.d.encode(key, value)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+	b.pending[key] = data
+	if len(b.pending) >= b.size {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+//Flush writes everything buffered so far in a single pipeline, and returns once it has been sent.
+//If it fails, the writes in the failed batch are dropped rather than retried.
+func (b *BatchWriter) Flush() error {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = make(map[string][]byte)
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	for k := range batch {
+		b.d.track(k)
+	}
+	client, err := b.d.begin()
+	if err != nil {
+		return err
+	}
+	defer b.d.end(client)
+	ctx := context.Background()
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for k, data := range batch {
+			pipe.Set(ctx, b.d.key(k), data, 0)
+		}
+		return nil
+	})
+	return err
+}
+
+//Close stops the background goroutine and flushes anything still buffered. Put returns ErrClosed afterwards.
+//It may be called more than once.
+func (b *BatchWriter) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+	close(b.stop)
+	<-b.done
+	return b.Flush()
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//ExecuteChunked runs the given function once as a transaction on the default database, and commits its changes in batches of at most batchSize commands.
+func ExecuteChunked(batchSize int, f func(t Transaction) error) error {
+	return db.ExecuteChunked(batchSize, f)
+}
+
+//ExecuteChunked is the Database equivalent of the package level ExecuteChunked.
+//It is intended for bulk imports which write too many keys to commit in a single pipeline, and it is NOT atomic:
+//keys are not watched, the function is never retried, and batches are sent one after another outside of MULTI/EXEC,
+//so other processes can see a partially applied import, and if a batch fails the batches before it remain applied.
+//Use Execute whenever the changes must be applied together.
+func (d *Database) ExecuteChunked(batchSize int, f func(t Transaction) error) error {
+	if batchSize < 1 {
+		return errors.New("database: batch size must be at least 1")
+	}
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	ctx := context.Background()
+	return client.Watch(ctx, func(tx *redis.Tx) error {
+		t := d.newTransaction(ctx, tx)
+		defer t.finish()
+		t.unwatched = true
+		if err := f(t); err != nil {
+			return err
+		}
+		d.track(t.changedKeys()...)
+		ops := t.operations()
+		for start := 0; start < len(ops); start += batchSize {
+			end := start + batchSize
+			if end > len(ops) {
+				end = len(ops)
+			}
+			_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, op := range ops[start:end] {
+					op(pipe)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
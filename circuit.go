@@ -0,0 +1,68 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrCircuitOpen is returned by Execute without contacting redis while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("database: circuit breaker open")
+
+var (
+	circuitFailures int
+	circuitCooldown time.Duration
+)
+
+//SetCircuitBreaker makes Execute fail fast with ErrCircuitOpen for the given cooldown after the given number of transactions
+//in a row have failed because of the connection, rather than every caller waiting on timeouts while redis is unhealthy.
+//Once the cooldown has passed a single transaction is let through to probe the connection; if it succeeds the breaker closes,
+//and if it fails the cooldown starts again. Each connection has its own breaker. Zero failures, the default, disables it.
+//It should be called before any transactions are executed.
+func SetCircuitBreaker(failures int, cooldown time.Duration) {
+	circuitFailures = failures
+	circuitCooldown = cooldown
+}
+
+//circuitBreaker tracks the connection failures of one connection.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+//allow returns ErrCircuitOpen if a transaction should not be attempted.
+func (b *circuitBreaker) allow() error {
+	if circuitFailures <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < circuitFailures {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+//record updates the breaker with the outcome of a transaction which was allowed.
+func (b *circuitBreaker) record(err error) {
+	if circuitFailures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if !isConnectionError(err) {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= circuitFailures {
+		b.openUntil = time.Now().Add(circuitCooldown)
+	}
+}
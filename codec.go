@@ -0,0 +1,285 @@
+package database
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+//Codec converts values to and from the bytes stored in the database.
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte, value interface{}) error
+}
+
+//GobCodec encodes values using encoding/gob. It is the default Codec.
+type GobCodec struct{}
+
+//gobBuffers holds buffers for GobCodec to encode into, so that writes do not allocate a new buffer every time.
+var gobBuffers = sync.Pool{New: func() interface{} {
+	atomic.AddUint64(&gobPool.allocated, 1)
+	return new(bytes.Buffer)
+}}
+
+//gobPoolLimit is the capacity above which a buffer is dropped rather than pooled, so that one large value does not pin memory.
+var gobPoolLimit = 1 << 20
+
+//gobPool counts how gobBuffers is used, for GetGobPoolStats.
+var gobPool struct {
+	gets, allocated, dropped uint64
+}
+
+//SetGobPoolLimit sets the largest capacity, in bytes, of a buffer which the GobCodec keeps for reuse after encoding a value.
+//Buffers which grew larger while encoding are dropped, so that a few large values do not pin memory. The default is 1MB.
+//A limit of zero disables pooling, so that every value is encoded into a new buffer.
+//It should be called before any transactions are executed.
+func SetGobPoolLimit(limit int) error {
+	if limit < 0 {
+		return errors.New("database: gob pool limit must not be negative")
+	}
+	gobPoolLimit = limit
+	return nil
+}
+
+//GobPoolStats describes how the GobCodec has reused its encode buffers since the program started.
+type GobPoolStats struct {
+	//Gets is the number of values encoded.
+	Gets uint64 `json:"gets"`
+	//Allocated is the number of buffers allocated because none could be reused; the rest of the gets reused a buffer.
+	Allocated uint64 `json:"allocated"`
+	//Dropped is the number of buffers not kept for reuse because they grew past the limit set by SetGobPoolLimit.
+	Dropped uint64 `json:"dropped"`
+}
+
+//GetGobPoolStats returns the GobPoolStats of the GobCodec, which is shared by every Database.
+func GetGobPoolStats() GobPoolStats {
+	return GobPoolStats{
+		Gets:      atomic.LoadUint64(&gobPool.gets),
+		Allocated: atomic.LoadUint64(&gobPool.allocated),
+		Dropped:   atomic.LoadUint64(&gobPool.dropped),
+	}
+}
+
+//Marshal encodes the given value.
+//Every value gets a new gob.Encoder, because an encoder only sends each type definition once,
+//and a value which relied on a definition sent with an earlier value could not be decoded on its own.
+//The buffer it is encoded into is pooled, and the result is copied out of it.
+func (GobCodec) Marshal(value interface{}) ([]byte, error) {
+	atomic.AddUint64(&gobPool.gets, 1)
+	buffer := gobBuffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer func() {
+		if buffer.Cap() <= gobPoolLimit {
+			gobBuffers.Put(buffer)
+		} else {
+			atomic.AddUint64(&gobPool.dropped, 1)
+		}
+	}()
+	if err := gob.NewEncoder(buffer).Encode(value); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buffer.Bytes()...), nil
+}
+
+//Unmarshal decodes the given data into the given interface, which should be a pointer.
+//Like Marshal, it uses a new gob.Decoder for every value, since each value carries its own type definitions.
+func (GobCodec) Unmarshal(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+//JSONCodec encodes values using encoding/json, so that they can be read by programs not written in Go.
+type JSONCodec struct{}
+
+//Marshal encodes the given value.
+func (JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+//Unmarshal decodes the given data into the given interface, which should be a pointer.
+func (JSONCodec) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+var codec Codec = GobCodec{}
+
+//SetCodec sets the Codec used by every Database which has not been given its own.
+//It should be called before any transactions are executed.
+func SetCodec(c Codec) {
+	codec = c
+}
+
+//SetCodec sets the Codec used by this Database, overriding the one set by the package level SetCodec.
+//Passing nil reverts to the package level Codec.
+func (d *Database) SetCodec(c Codec) {
+	d.codec = c
+}
+
+//WithCodec sets the Codec used by the Database, as its SetCodec does.
+func WithCodec(c Codec) Option {
+	return func(d *Database) {
+		d.codec = c
+	}
+}
+
+//encoding returns the Codec in use by the Database.
+func (d *Database) encoding() Codec {
+	if d.codec != nil {
+		return d.codec
+	}
+	return codec
+}
+
+//encodeError describes a failure to encode the value for the given key with the given Codec.
+func encodeError(key string, c Codec, err error) error {
+	if _, ok := c.(GobCodec); ok {
+		return fmt.Errorf("database: encoding key %q: %w (did you gob.Register the type?)", key, err)
+	}
+	return fmt.Errorf("database: encoding key %q: %w", key, err)
+}
+
+//RegisterType registers the concrete type of the given value with gob,
+//which is required before values of that type can be stored in an interface field using the GobCodec.
+func RegisterType(value interface{}) {
+	gob.Register(value)
+}
+
+//WithTypes registers the concrete type of each of the given values with gob when the Database is created,
+//so that every type stored in an interface field is listed in one place, rather than in init functions across the program.
+//Registration is global, so the types are also available to every other Database.
+func WithTypes(values ...interface{}) Option {
+	return func(d *Database) {
+		for _, v := range values {
+			RegisterType(v)
+		}
+	}
+}
+
+//ErrNilValue is returned when writing nil, or a nil pointer, as a value.
+//There is no tombstone for nil: to record that a key has no value, Delete it, after which Read returns ErrNotFound.
+var ErrNilValue = errors.New("database: cannot write a nil value")
+
+//isNil reports whether the given value is nil, or a nil pointer.
+func isNil(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+//encode converts the given value, to be stored at the given key, into the form stored in the database.
+//Nil values are rejected with ErrNilValue before reaching the Codec.
+func (d *Database) encode(key string, value interface{}) ([]byte, error) {
+	return encodeWith(key, value, d.encoding(), nil)
+}
+
+//encodeWith encodes the given value with the given Codec, prepending header, if any, to the encoded form before it is compressed.
+func encodeWith(key string, value interface{}, c Codec, header []byte) ([]byte, error) {
+	if isNil(value) {
+		return nil, fmt.Errorf("%w at key %q", ErrNilValue, key)
+	}
+	if _, ok := c.(GobCodec); ok && autoRegister {
+		if err := registerInterfaces(value); err != nil {
+			return nil, err
+		}
+	}
+	data, err := c.Marshal(value)
+	if err != nil {
+		return nil, encodeError(key, c, err)
+	}
+	if header != nil {
+		data = append(append(make([]byte, 0, len(header)+len(data)), header...), data...)
+	}
+	data, err = compress(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(key, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//unmarshal decodes uncompressed data with the given Codec, or if it is nil, the Codec the data was tagged with by WriteCodec,
+//or failing that the Codec of the Database.
+func (d *Database) unmarshal(data []byte, value interface{}, c Codec) error {
+	id, data, tagged := splitCodec(data)
+	if c == nil && tagged {
+		var err error
+		if c, err = taggedCodec(id); err != nil {
+			return err
+		}
+	}
+	if c == nil {
+		c = d.encoding()
+	}
+	return c.Unmarshal(data, value)
+}
+
+var maxValueSize int
+
+//SetMaxValueSize limits the size of values written to the database to n bytes, after encoding and compression.
+//Writing a larger value returns an error before anything is sent to redis. Zero, the default, means no limit.
+//It should be called before any transactions are executed.
+func SetMaxValueSize(n int) {
+	maxValueSize = n
+}
+
+//checkSize returns an error if the given data, to be stored at the given key, exceeds the limit set by SetMaxValueSize.
+func checkSize(key string, data []byte) error {
+	if maxValueSize > 0 && len(data) > maxValueSize {
+		return fmt.Errorf("database: value for key %q is %d bytes, exceeds limit %d", key, len(data), maxValueSize)
+	}
+	return nil
+}
+
+//decode converts data stored at the given key into the given interface, which should be a pointer.
+//Values written by WriteCodec are decoded with the Codec they were written with, and others with the Codec of the Database.
+//Failures are reported as a *DecodeError.
+func (d *Database) decode(key string, data []byte, value interface{}) error {
+	return d.decodeWith(key, data, value, nil)
+}
+
+//decodeWith decodes like decode, but uses the given Codec, unless it is nil, whichever Codec the value was written with.
+func (d *Database) decodeWith(key string, data []byte, value interface{}, c Codec) error {
+	data, err := decompress(data)
+	if err == nil {
+		err = d.unmarshal(data, value, c)
+	}
+	if err != nil {
+		return &DecodeError{Key: key, Err: err}
+	}
+	if v, ok := value.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("database: invalid value at key %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+//Validator can be implemented by stored types to check their invariants.
+//Whenever a value is read into a type implementing it, Validate is called after decoding, and its error is returned wrapped,
+//so that corrupt or stale data is caught where it is read.
+type Validator interface {
+	Validate() error
+}
+
+//DecodeError is returned when a key exists but the stored data cannot be decoded into the given value,
+//for example because it is corrupt or was written using a different type.
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("database: decoding key %q: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
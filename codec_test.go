@@ -0,0 +1,118 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+)
+
+type benchValue struct {
+	Name  string
+	Tags  []string
+	Count int
+	Data  map[string]float64
+}
+
+func newBenchValue() benchValue {
+	return benchValue{
+		Name:  "example",
+		Tags:  []string{"a", "b", "c"},
+		Count: 42,
+		//A single entry, since gob encodes maps in random order.
+		Data: map[string]float64{"y": 2.5},
+	}
+}
+
+//TestGobCodecValuesAreIndependent checks that pooled buffers do not carry type definitions from one value to the next.
+func TestGobCodecValuesAreIndependent(t *testing.T) {
+	first, err := GobCodec{}.Marshal(newBenchValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GobCodec{}.Marshal(newBenchValue())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("encoding the same value twice gave different results:\n%x\n%x", first, second)
+	}
+	var decoded benchValue
+	if err := (GobCodec{}).Unmarshal(second, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "example" || decoded.Count != 42 || len(decoded.Tags) != 3 || decoded.Data["y"] != 2.5 {
+		t.Fatalf("decoded %+v", decoded)
+	}
+}
+
+func TestGobPoolStats(t *testing.T) {
+	before := GetGobPoolStats()
+	for i := 0; i < 10; i++ {
+		if _, err := (GobCodec{}).Marshal(newBenchValue()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after := GetGobPoolStats()
+	if after.Gets-before.Gets != 10 {
+		t.Fatalf("expected 10 gets, got %d", after.Gets-before.Gets)
+	}
+	if after.Allocated-before.Allocated > after.Gets-before.Gets {
+		t.Fatalf("allocated %d buffers for %d gets", after.Allocated-before.Allocated, after.Gets-before.Gets)
+	}
+}
+
+func TestSetGobPoolLimit(t *testing.T) {
+	defer SetGobPoolLimit(gobPoolLimit)
+	if err := SetGobPoolLimit(-1); err == nil {
+		t.Fatal("expected an error for a negative limit")
+	}
+	if err := SetGobPoolLimit(0); err != nil {
+		t.Fatal(err)
+	}
+	before := GetGobPoolStats()
+	if _, err := (GobCodec{}).Marshal(newBenchValue()); err != nil {
+		t.Fatal(err)
+	}
+	if dropped := GetGobPoolStats().Dropped - before.Dropped; dropped != 1 {
+		t.Fatalf("expected the buffer to be dropped with pooling disabled, dropped %d", dropped)
+	}
+}
+
+func benchmarkGobMarshal(b *testing.B, limit int) {
+	defer SetGobPoolLimit(gobPoolLimit)
+	if err := SetGobPoolLimit(limit); err != nil {
+		b.Fatal(err)
+	}
+	v := newBenchValue()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := (GobCodec{}).Marshal(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkGobCodecMarshal encodes with the buffer pool, as the GobCodec does by default.
+func BenchmarkGobCodecMarshal(b *testing.B) {
+	benchmarkGobMarshal(b, 1<<20)
+}
+
+//BenchmarkGobCodecMarshalUnpooled encodes with pooling disabled, allocating a buffer for every value as the GobCodec used to.
+func BenchmarkGobCodecMarshalUnpooled(b *testing.B) {
+	benchmarkGobMarshal(b, 0)
+}
+
+func BenchmarkGobCodecUnmarshal(b *testing.B) {
+	data, err := GobCodec{}.Marshal(newBenchValue())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v benchValue
+		if err := (GobCodec{}).Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
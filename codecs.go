@@ -0,0 +1,103 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//codecHeader marks a stored value as written by WriteCodec. It is followed by the id of the Codec which encoded the rest.
+//Like compressedHeader, it begins with a zero byte, which the output of the built in Codecs never does.
+var codecHeader = []byte{0, 'c'}
+
+//codecs holds every Codec which WriteCodec can use, by id.
+var codecs = map[byte]Codec{
+	'g': GobCodec{},
+	'j': JSONCodec{},
+	'p': ProtoCodec{},
+}
+
+//RegisterCodec makes the given Codec usable with WriteCodec, under the given id, which is stored with every value it writes.
+//The GobCodec, JSONCodec and ProtoCodec are registered already, as 'g', 'j' and 'p'.
+//Ids are permanent: values written with a Codec can only be read back automatically while it is registered under the same id.
+//It should be called before any transactions are executed.
+func RegisterCodec(id byte, c Codec) error {
+	if c == nil {
+		return errors.New("database: cannot register a nil Codec")
+	}
+	if existing, ok := codecs[id]; ok {
+		return fmt.Errorf("database: codec id %q is already registered to %T", id, existing)
+	}
+	codecs[id] = c
+	return nil
+}
+
+//codecID returns the id the given Codec is registered under.
+func codecID(c Codec) (byte, bool) {
+	//Comparing interfaces holding the same uncomparable type panics, so such Codecs can never be found.
+	if c == nil || !reflect.TypeOf(c).Comparable() {
+		return 0, false
+	}
+	for id, registered := range codecs {
+		if registered == c {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+//taggedCodec returns the Codec registered under the given id, which a value was tagged with by WriteCodec.
+func taggedCodec(id byte) (Codec, error) {
+	c, ok := codecs[id]
+	if !ok {
+		return nil, fmt.Errorf("database: value was written with unregistered codec %q", id)
+	}
+	return c, nil
+}
+
+//splitCodec returns the id of the Codec which the given uncompressed data was tagged with by WriteCodec and the data without its tag,
+//or the data unchanged if it is not tagged.
+func splitCodec(data []byte) (id byte, rest []byte, tagged bool) {
+	if !bytes.HasPrefix(data, codecHeader) || len(data) == len(codecHeader) {
+		return 0, data, false
+	}
+	return data[len(codecHeader)], data[len(codecHeader)+1:], true
+}
+
+//WriteCodec writes the given value at the given key like Write, but encodes it with the given Codec instead of the Codec of the Database.
+//The value is tagged with the id the Codec was registered under, so that Read decodes it with the same Codec,
+//which allows keys written with different Codecs to be mixed, for example while migrating values from one Codec to another.
+//The Codec must have been registered with RegisterCodec, unless it is one of the built in Codecs.
+//Other programs reading a tagged value must skip its three byte tag, so values shared with them are best written by a Database using their Codec.
+func (t Transaction) WriteCodec(key string, value interface{}, c Codec) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	id, ok := codecID(c)
+	if !ok {
+		return fmt.Errorf("database: cannot write key %q with unregistered codec %T", key, c)
+	}
+	data, err := encodeWith(key, value, c, append(append([]byte{}, codecHeader...), id))
+	if err != nil {
+		return err
+	}
+	t.stage(key, data, 0)
+	return nil
+}
+
+//ReadCodec reads the given key into the given interface like Read, but decodes it with the given Codec,
+//whichever Codec it was written with. It can read values written by WriteCodec as well as by Write.
+func (t Transaction) ReadCodec(key string, value interface{}, c Codec) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("database: ReadCodec requires a Codec")
+	}
+	data, err := t.fetch(key)
+	if err != nil {
+		return err
+	}
+	return t.d.decodeWith(key, data, value, c)
+}
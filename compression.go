@@ -0,0 +1,89 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+)
+
+//Compressor compresses encoded values before they are stored.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+//GzipCompressor compresses values using compress/gzip.
+type GzipCompressor struct {
+	//Level is the gzip compression level, or zero for gzip.DefaultCompression.
+	Level int
+}
+
+//Compress compresses the given data.
+func (c GzipCompressor) Compress(data []byte) ([]byte, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	buffer := bytes.NewBuffer(nil)
+	w, err := gzip.NewWriterLevel(buffer, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+//Decompress decompresses the given data.
+func (c GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+//compressedHeader marks a stored value as compressed.
+//Neither gob nor JSON output can begin with a zero byte, so uncompressed values are never mistaken for compressed ones.
+var compressedHeader = []byte{0, 'z'}
+
+var compressor Compressor
+var compressionThreshold int
+
+//SetCompression compresses every value whose encoded form is larger than threshold bytes using the given Compressor.
+//Passing nil disables compression. Values stored uncompressed, including those written before compression was enabled,
+//can always be read, but compressed values can only be read while a Compressor is set.
+//It should be called before any transactions are executed.
+func SetCompression(c Compressor, threshold int) {
+	compressor = c
+	compressionThreshold = threshold
+}
+
+//compress compresses the given encoded value if compression is enabled and the value is large enough.
+func compress(data []byte) ([]byte, error) {
+	if compressor == nil || len(data) <= compressionThreshold {
+		return data, nil
+	}
+	compressed, err := compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, compressedHeader...), compressed...), nil
+}
+
+//decompress reverses compress.
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, compressedHeader) {
+		return data, nil
+	}
+	if compressor == nil {
+		return nil, errors.New("database: value is compressed but no Compressor is set")
+	}
+	return compressor.Decompress(data[len(compressedHeader):])
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//connection is a redis client which can be replaced if the connection is lost.
+type connection struct {
+	mu      sync.RWMutex
+	current *redis.Client
+	options *redis.Options
+	//users counts, for every client which has not been closed yet, the callers which have begun using it but not yet released it.
+	users map[*redis.Client]*sync.WaitGroup
+	//reconnecting serializes reconnects, so that only one new client is made when several transactions lose the connection at once.
+	reconnecting sync.Mutex
+	breaker      circuitBreaker
+}
+
+//ErrClosed is returned when a Database is used after it has been terminated, or before it has been connected.
+var ErrClosed = errors.New("database: database is closed")
+
+//install makes the given client, which may be nil, the current one, and returns the client it replaces.
+//The mutex must be held, unless the connection is not yet shared.
+func (c *connection) install(client *redis.Client) *redis.Client {
+	old := c.current
+	c.current = client
+	if c.users == nil {
+		c.users = make(map[*redis.Client]*sync.WaitGroup)
+	}
+	if client != nil {
+		c.users[client] = new(sync.WaitGroup)
+	}
+	return old
+}
+
+//retire waits until every caller using the given client, which must no longer be current, has released it, then closes it.
+func (c *connection) retire(client *redis.Client) error {
+	c.mu.RLock()
+	users := c.users[client]
+	c.mu.RUnlock()
+	users.Wait()
+	err := client.Close()
+	c.mu.Lock()
+	delete(c.users, client)
+	c.mu.Unlock()
+	return err
+}
+
+//begin returns the redis client currently in use by the Database, which must be released by calling end.
+//The client is not closed, by Terminate or Reconnect, until every caller which has begun using it has released it.
+func (d *Database) begin() (*redis.Client, error) {
+	if d == nil {
+		return nil, ErrClosed
+	}
+	d.conn.mu.RLock()
+	defer d.conn.mu.RUnlock()
+	if d.conn.current == nil {
+		return nil, ErrClosed
+	}
+	d.conn.users[d.conn.current].Add(1)
+	return d.conn.current, nil
+}
+
+//end releases a client returned by begin.
+func (d *Database) end(client *redis.Client) {
+	d.conn.mu.RLock()
+	users := d.conn.users[client]
+	d.conn.mu.RUnlock()
+	users.Done()
+}
+
+//Client returns the go-redis client currently used by the default database.
+func Client() *redis.Client {
+	return db.Client()
+}
+
+//Client returns the go-redis client currently used by the Database, or nil if it has been terminated, as an escape hatch for commands this package does not wrap.
+//It should be a last resort: commands sent with it bypass transactions, the Codec, compression and the key prefix.
+//The client is closed when the Database is terminated, and replaced when it reconnects, so it should be fetched again rather than kept.
+func (d *Database) Client() *redis.Client {
+	if d == nil {
+		return nil
+	}
+	d.conn.mu.RLock()
+	defer d.conn.mu.RUnlock()
+	return d.conn.current
+}
+
+//Reconnect replaces the connection to the default database.
+func Reconnect() error {
+	return db.Reconnect()
+}
+
+//Reconnect replaces the connection to the database with a new one, using the options it was created with.
+//The old connection is only closed once the new one has been established, and every transaction still using it has finished.
+//Execute calls it automatically if a transaction fails because the connection has been lost.
+func (d *Database) Reconnect() error {
+	return d.reconnect(nil)
+}
+
+//reconnect replaces the connection like Reconnect, unless failed is not nil and has already been replaced,
+//so that transactions which lost the same connection only reconnect once between them.
+func (d *Database) reconnect(failed *redis.Client) error {
+	if d == nil {
+		return ErrClosed
+	}
+	d.conn.reconnecting.Lock()
+	defer d.conn.reconnecting.Unlock()
+	d.conn.mu.RLock()
+	current := d.conn.current
+	d.conn.mu.RUnlock()
+	if current == nil {
+		return ErrClosed
+	}
+	if failed != nil && failed != current {
+		return nil
+	}
+	client := redis.NewClient(d.conn.options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return err
+	}
+	d.conn.mu.Lock()
+	if d.conn.current == nil {
+		d.conn.mu.Unlock()
+		client.Close()
+		return ErrClosed
+	}
+	old := d.conn.install(client)
+	d.conn.mu.Unlock()
+	go func() {
+		if err := d.conn.retire(old); err != nil {
+			logger.Println("failed to close replaced connection:", err)
+		}
+	}()
+	return nil
+}
+
+//isConnectionError reports whether the given error was caused by a problem with the connection to redis,
+//rather than a missing key, a conflict, or an error returned by the caller.
+func isConnectionError(err error) bool {
+	if err == nil || err == redis.Nil || err == redis.TxFailedErr {
+		return false
+	}
+	//A client which was closed by Terminate, or after being replaced, is retried with the current one.
+	if err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, redis.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
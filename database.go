@@ -2,119 +2,1322 @@ package database //import "github.com/clayts/database"
 
 import (
 	"bytes"
-	"encoding/gob"
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/clayts/insist"
-	"github.com/go-redis/redis/v7"
+	"github.com/redis/go-redis/v9"
 )
 
-var db *redis.Client
+//Database is a connection to a redis database.
+type Database struct {
+	conn   *connection
+	codec  Codec
+	prefix string
+	//session records the keys written, if session tracking is enabled.
+	session *session
+}
+
+//Option configures a Database when it is created.
+type Option func(d *Database)
+
+//WithPrefix transparently prepends the given prefix to every key used by the Database.
+//Keys returned by the Database have the prefix removed, and Flush only deletes keys with the prefix.
+func WithPrefix(prefix string) Option {
+	return func(d *Database) {
+		d.prefix = prefix
+	}
+}
+
+//With returns a copy of the Database with the given options applied, which shares its connection rather than dialing again.
+//It is meant for options such as WithCodec and WithPrefix, for example to give a public API a JSON view of the same data
+//which the rest of the program reads with gob. Options which configure the connection itself, such as WithPoolSize or WithDB,
+//have no effect on the copy. Terminating either Database closes the shared connection, so only the original should be terminated.
+func (d *Database) With(opts ...Option) *Database {
+	derived := *d
+	options := *d.conn.options
+	derived.conn = &connection{options: &options}
+	for _, o := range opts {
+		o(&derived)
+	}
+	derived.conn = d.conn
+	return &derived
+}
+
+//db is the default Database used by the package level functions.
+var db *Database
 
 //ErrNotFound is returned when a key is not found
-var ErrNotFound = redis.Nil
+var ErrNotFound = errors.New("database: key not found")
 
 var maxDatabaseRetryAttempts = 3
 
+//SetMaxRetries sets the number of times Execute will attempt a transaction before giving up.
+//It should be called before any transactions are executed.
+func SetMaxRetries(n int) error {
+	if n < 1 {
+		return errors.New("database: max retries must be at least 1")
+	}
+	maxDatabaseRetryAttempts = n
+	return nil
+}
+
 func init() {
-	redisURL := os.Getenv("REDIS_URL")
-	log.Println("initialising database")
-	opt, err := redis.ParseURL(redisURL)
-	insist.IsNil(err)
-	db = redis.NewClient(opt)
-	insist.Is(insist.OnString(db.Ping().Result()), "PONG")
+	if os.Getenv("REDIS_URL") != "" {
+		MustConnect()
+	}
+}
+
+//NewDatabase connects to the redis database at the given url.
+func NewDatabase(url string, opts ...Option) (*Database, error) {
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	d := &Database{conn: &connection{options: opt}}
+	for _, o := range opts {
+		o(d)
+	}
+	client := redis.NewClient(opt)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	d.conn.install(client)
+	return d, nil
+}
+
+//Connect connects the default database to the redis database at the given url, replacing any existing connection.
+func Connect(url string, opts ...Option) error {
+	d, err := NewDatabase(url, opts...)
+	if err != nil {
+		return err
+	}
+	Terminate()
+	db = d
+	return nil
+}
+
+//ErrURLNotSet is returned by ConnectEnv when REDIS_URL is empty or missing.
+var ErrURLNotSet = errors.New("database: REDIS_URL is not set")
+
+//ConnectEnv connects the default database to the redis database at REDIS_URL.
+//It returns ErrURLNotSet rather than falling back to a local redis if the variable is empty.
+func ConnectEnv(opts ...Option) error {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		return ErrURLNotSet
+	}
+	return Connect(url, opts...)
+}
+
+//MustConnect connects the default database to the redis database at REDIS_URL, and panics if it cannot, or if REDIS_URL is not set.
+func MustConnect() {
+	insist.IsNil(ConnectEnv())
+}
+
+//Ping checks that the default database is reachable.
+func Ping() error {
+	return db.Ping()
+}
+
+//Ping checks that the database is reachable.
+func (d *Database) Ping() error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	return client.Ping(context.Background()).Err()
+}
+
+//Flush deletes all information in the default database
+func Flush() error {
+	return db.Flush()
+}
+
+//MustFlush deletes all information in the default database, and panics if it cannot.
+func MustFlush() {
+	insist.IsNil(Flush())
+}
+
+//Flush deletes all information in the database.
+//If the Database has a prefix, only keys with that prefix are deleted.
+func (d *Database) Flush() error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	if d.prefix == "" {
+		result, err := client.FlushDB(context.Background()).Result()
+		if err != nil {
+			return err
+		}
+		logger.Println("flushing database:", result)
+		return nil
+	}
+	if err := d.FlushPattern("*"); err != nil {
+		return err
+	}
+	logger.Println("flushing database: deleted keys with prefix", d.prefix)
+	return nil
 }
 
-//Flush deletes all information in the database
-func Flush() {
-	log.Println("flushing database:", insist.OnString(db.FlushDB().Result()))
+//MustFlush is the Database equivalent of the package level MustFlush.
+func (d *Database) MustFlush() {
+	insist.IsNil(d.Flush())
+}
+
+//key returns the redis key for the given key.
+func (d *Database) key(key string) string {
+	return d.prefix + key
+}
+
+//keys returns the redis keys for the given keys.
+func (d *Database) keys(keys []string) []string {
+	if d.prefix == "" {
+		return keys
+	}
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = d.key(k)
+	}
+	return prefixed
 }
 
 //Terminate must be called before the program terminates.
+//Afterwards the package level functions return ErrClosed until the default database is connected again.
 func Terminate() {
 	if db != nil {
-		insist.IsNil(db.Close())
-		db = nil
+		db.Terminate()
+	}
+}
+
+//Terminate closes the connection to the database.
+//It waits for operations already in progress to finish, and any started afterwards return ErrClosed.
+//It is safe to call Terminate more than once, and concurrently with other operations.
+func (d *Database) Terminate() {
+	d.conn.mu.Lock()
+	client := d.conn.install(nil)
+	d.conn.mu.Unlock()
+	if client != nil {
+		insist.IsNil(d.conn.retire(client))
 	}
 }
 
 //Transaction is an object which allows interaction with the database.
 type Transaction struct {
+	d       *Database
+	ctx     context.Context
 	tx      *redis.Tx
-	cache   map[string]string
+	cache   map[string][]byte
 	written map[string]struct{}
+	deleted map[string]struct{}
+	ttl     map[string]time.Duration
+	queued  map[string][]func(pipe redis.Pipeliner)
+	//read holds every key the transaction has read from redis, for the Auditor.
+	read map[string]struct{}
+	//seen holds the value of every key fetched from redis, or nil if it did not exist, to find conflicts.
+	seen map[string][]byte
+	//versioned caches values read or staged by the versioned methods, which are stored as hashes rather than strings.
+	versioned map[string]versionedValue
+	//counters holds the projected value of every hash field incremented by HIncrement, including pending increments.
+	counters map[hashField]int64
+	//hashWrites holds every hash field written by HWrite, which cannot be incremented afterwards.
+	hashWrites map[hashField]struct{}
+	//done is set once the attempt the Transaction belongs to has finished, shared by every copy of the Transaction.
+	done *int32
+	//readOnly is set for transactions created by View, which do not watch keys and cannot make changes.
+	readOnly bool
+	//unwatched is set for transactions which do not watch keys but can still make changes, such as those created by Atomic and ExecuteChunked.
+	unwatched bool
 }
 
+//ErrReadOnly is returned when a transaction created by View attempts to make a change.
+var ErrReadOnly = errors.New("database: transaction is read only")
+
 //Execute creates a temporary Transaction object and executes the given function.
 //Expect the function to be run several times, in case another process changes the data while it's being executed (see redis optimistic locking).
 //Because of this, be very careful about modifying data outside of the database in this function.
-//If the function returns an error, the transaction is aborted and no changes are made.
+//If the function returns an error, the transaction is aborted, no changes are made, and the error is returned without trying again,
+//unless a predicate set with SetRetryPredicate says otherwise.
+//If the connection to redis is lost, Execute reconnects once and tries again.
 func Execute(f func(t Transaction) error) error {
-	var err error
-	for i := 0; i < maxDatabaseRetryAttempts; i++ {
-		err = db.Watch(func(tx *redis.Tx) error {
-			t := Transaction{}
-			t.tx = tx
-			t.cache = make(map[string]string)
-			t.written = make(map[string]struct{})
+	return db.Execute(f)
+}
+
+//ExecuteContext is like Execute, but stops retrying and returns the context's error once the context is done.
+//The context is also used for every operation performed by the Transaction.
+func ExecuteContext(ctx context.Context, f func(t Transaction) error) error {
+	return db.ExecuteContext(ctx, f)
+}
+
+//Execute is the Database equivalent of the package level Execute.
+func (d *Database) Execute(f func(t Transaction) error) error {
+	return d.ExecuteContext(context.Background(), f)
+}
+
+//ExecuteContext is the Database equivalent of the package level ExecuteContext.
+func (d *Database) ExecuteContext(ctx context.Context, f func(t Transaction) error) error {
+	_, err := d.executeN(ctx, f)
+	return err
+}
+
+//ExecuteN is like Execute, but also returns the number of times the function was attempted.
+func ExecuteN(f func(t Transaction) error) (attempts int, err error) {
+	return db.ExecuteN(f)
+}
+
+//ExecuteN is the Database equivalent of the package level ExecuteN.
+func (d *Database) ExecuteN(f func(t Transaction) error) (attempts int, err error) {
+	return d.executeN(context.Background(), f)
+}
+
+//executeN runs a transaction and notifies the Observer and auditor of the outcome.
+func (d *Database) executeN(ctx context.Context, f func(t Transaction) error) (int, error) {
+	if d != nil {
+		if err := d.conn.breaker.allow(); err != nil {
+			if observer != nil {
+				observer.OnError(err)
+			}
+			return 0, err
+		}
+	}
+	start := time.Now()
+	attempts, last, err := d.execute(ctx, f)
+	if d != nil {
+		d.conn.breaker.record(err)
+	}
+	elapsed := time.Since(start)
+	written := last.changedKeys()
+	if slowTransactionThreshold > 0 && elapsed > slowTransactionThreshold {
+		touched := len(last.read)
+		for _, k := range written {
+			if _, ok := last.read[k]; !ok {
+				touched++
+			}
+		}
+		logger.Println("slow transaction: took", elapsed, "over", attempts, "attempts, touching", touched, "keys")
+	}
+	if err != nil {
+		if observer != nil {
+			observer.OnError(err)
+		}
+		return attempts, err
+	}
+	d.track(written...)
+	if observer != nil {
+		observer.OnCommit(len(written), elapsed)
+	}
+	if auditor != nil {
+		read := make([]string, 0, len(last.read))
+		for k := range last.read {
+			read = append(read, k)
+		}
+		sort.Strings(read)
+		auditor(read, written)
+	}
+	return attempts, nil
+}
+
+var slowTransactionThreshold time.Duration
+
+//SetSlowTransactionThreshold makes Execute log every transaction which takes longer than the given duration across all of its attempts,
+//with the time taken, the number of attempts and the number of keys read or changed by the last attempt. Zero, the default, disables it.
+//It should be called before any transactions are executed.
+func SetSlowTransactionThreshold(d time.Duration) {
+	slowTransactionThreshold = d
+}
+
+//execute runs the retry loop for a transaction, returning the number of attempts made and the Transaction used by the last attempt,
+//which is the one that committed if err is nil.
+func (d *Database) execute(ctx context.Context, f func(t Transaction) error) (attempts int, last Transaction, err error) {
+	limit := maxDatabaseRetryAttempts
+	reconnected := false
+	start := time.Now()
+	var previous *writeSet
+	budgetSpent := false
+	for attempts < limit {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return attempts, last, ctxErr
+		}
+		if attempts > 0 {
+			wait := backoff(attempts)
+			if retryBudget > 0 && time.Since(start)+wait >= retryBudget {
+				logger.Println("retry budget exhausted in transaction")
+				budgetSpent = true
+				break
+			}
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return attempts, last, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		attempts++
+		if attempts > 1 && observer != nil {
+			observer.OnRetry(attempts)
+		}
+		client, beginErr := d.begin()
+		if beginErr != nil {
+			return attempts, last, beginErr
+		}
+		err = client.Watch(ctx, func(tx *redis.Tx) error {
+			t := d.newTransaction(ctx, tx)
+			defer t.finish()
+			last = t
 			if err := f(t); err != nil {
 				return err
 			}
-			_, err := tx.TxPipelined(func(pipe redis.Pipeliner) error {
-				for k := range t.written {
-					err := pipe.Set(k, t.cache[k], 0).Err()
-					if err != nil {
-						return err
-					}
+			if detectSideEffects {
+				current := t.writeSet()
+				if current.sideEffect(previous) {
+					logger.Println("transaction may have side effects: attempt", attempts, "read the same values as the last but staged different changes to", t.changedKeys())
 				}
-				return nil
-			})
+				previous = current
+			}
+			_, err := tx.TxPipelined(ctx, t.commit)
 			return err
 		})
+		d.end(client)
 		if err == nil {
-			return nil
+			return attempts, last, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return attempts, last, ctxErr
+		}
+		if !shouldRetry(err) {
+			return attempts, last, err
+		}
+		if isConnectionError(err) && !reconnected {
+			reconnected = true
+			logger.Println("connection lost in transaction, reconnecting:", err)
+			if d.reconnect(client) == nil {
+				limit++
+			}
 		}
 	}
-	log.Println("max retries reached in transaction")
-	return err
+	if attempts >= limit {
+		logger.Println("max retries reached in transaction")
+	}
+	if err == redis.TxFailedErr {
+		err = exhaustedError{d.conflictError(last)}
+	} else if budgetSpent {
+		err = exhaustedError{err}
+	}
+	return attempts, last, err
+}
+
+var retryPredicate func(err error) bool
+
+//SetRetryPredicate sets the function which decides whether Execute should try a transaction again after it fails with the given error,
+//or nil to restore the default, which only retries conflicts with other processes and lost connections.
+//Any other error, including one returned by the transaction function, is returned straight away.
+//At most the number of attempts set by SetMaxRetries are made either way.
+//It should be called before any transactions are executed.
+func SetRetryPredicate(f func(err error) bool) {
+	retryPredicate = f
+}
+
+//shouldRetry reports whether a transaction which failed with the given error should be attempted again.
+func shouldRetry(err error) bool {
+	if retryPredicate != nil {
+		return retryPredicate(err)
+	}
+	return errors.Is(err, redis.TxFailedErr) || isConnectionError(err)
+}
+
+//ConflictError describes the conflict which made a transaction give up, and is wrapped by the error matching ErrRetriesExhausted.
+//It can be retrieved with errors.As, and matches redis.TxFailedErr with errors.Is.
+type ConflictError struct {
+	//Keys lists, in sorted order, the keys fetched by the final attempt whose values had changed by the time it gave up.
+	//It is a best effort: keys which were only watched, checked with Exists, or read as lists, hashes, sets or sorted sets are not compared,
+	//and a key changed back to its original value is missed, so it may be empty.
+	Keys []string
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Keys) == 0 {
+		return "database: transaction conflicted"
+	}
+	return "database: transaction conflicted on " + strings.Join(e.Keys, ", ")
+}
+
+func (e *ConflictError) Unwrap() error {
+	return redis.TxFailedErr
+}
+
+//conflictError compares the values fetched by the given Transaction with their current values, to report which changed.
+func (d *Database) conflictError(t Transaction) *ConflictError {
+	e := &ConflictError{}
+	if len(t.seen) == 0 {
+		return e
+	}
+	keys := make([]string, 0, len(t.seen))
+	for k := range t.seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	client, err := d.begin()
+	if err != nil {
+		return e
+	}
+	defer d.end(client)
+	current, err := client.MGet(context.Background(), d.keys(keys)...).Result()
+	if err != nil {
+		return e
+	}
+	for i, k := range keys {
+		s, exists := current[i].(string)
+		old := t.seen[k]
+		if exists != (old != nil) || s != string(old) {
+			e.Keys = append(e.Keys, k)
+		}
+	}
+	return e
+}
+
+//ErrRetriesExhausted is returned by Execute when a transaction still conflicts with changes made by other processes after every attempt, or once the budget set by SetRetryBudget runs out.
+//The returned error wraps the underlying cause, and can be detected with errors.Is.
+//Errors returned by the transaction function itself are passed through unchanged.
+var ErrRetriesExhausted = errors.New("database: transaction retries exhausted")
+
+//exhaustedError wraps the conflict which caused a transaction to give up,
+//or whichever retried error the last attempt failed with if the retry budget ran out.
+type exhaustedError struct {
+	err error
+}
+
+func (e exhaustedError) Error() string {
+	return ErrRetriesExhausted.Error() + ": " + e.err.Error()
+}
+
+func (e exhaustedError) Is(target error) bool {
+	return target == ErrRetriesExhausted
+}
+
+func (e exhaustedError) Unwrap() error {
+	return e.err
+}
+
+//View executes the given function with a read only Transaction.
+//Keys are not watched and nothing is committed, so the function is only run once,
+//but values read are not guaranteed to be consistent with each other if other processes are making changes.
+//Any attempt to make a change returns ErrReadOnly.
+func View(f func(t Transaction) error) error {
+	return db.View(f)
+}
+
+//View is the Database equivalent of the package level View.
+func (d *Database) View(f func(t Transaction) error) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	return client.Watch(context.Background(), func(tx *redis.Tx) error {
+		t := d.newTransaction(context.Background(), tx)
+		defer t.finish()
+		t.readOnly = true
+		return f(t)
+	})
+}
+
+//Atomic runs the given function once as a transaction on the default database, applying its changes with MULTI/EXEC but without watching any keys.
+func Atomic(f func(t Transaction) error) error {
+	return db.Atomic(f)
+}
+
+//Atomic is the Database equivalent of the package level Atomic.
+//The changes are applied together or not at all, but because nothing is watched, changes made by other processes
+//to keys the function read are not detected, and the function is never retried. It suits blind writes, where it is
+//faster than Execute; use Execute when the changes depend on values read. Watch has no effect in the function.
+//It only fails because of the function's own error or a problem sending the commands to redis.
+func (d *Database) Atomic(f func(t Transaction) error) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	ctx := context.Background()
+	return client.Watch(ctx, func(tx *redis.Tx) error {
+		t := d.newTransaction(ctx, tx)
+		defer t.finish()
+		t.unwatched = true
+		if err := f(t); err != nil {
+			return err
+		}
+		if _, err := tx.TxPipelined(ctx, t.commit); err != nil {
+			return err
+		}
+		d.track(t.changedKeys()...)
+		return nil
+	})
+}
+
+//Simulate runs the given function once as a transaction on the default database, but returns the changes it would make instead of committing them.
+func Simulate(f func(t Transaction) error) (writes map[string][]byte, deletes []string, err error) {
+	return db.Simulate(f)
+}
+
+//Simulate is the Database equivalent of the package level Simulate.
+//Writes maps each key written to the bytes which would be stored, and deletes lists each key deleted in sorted order.
+//Only changes made with Write, Delete and the methods built on them are reported;
+//commands applied at commit such as Increment, PushBack or HWrite are not.
+//If the function returns an error, it is returned along with no changes.
+func (d *Database) Simulate(f func(t Transaction) error) (writes map[string][]byte, deletes []string, err error) {
+	client, err := d.begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer d.end(client)
+	err = client.Watch(context.Background(), func(tx *redis.Tx) error {
+		t := d.newTransaction(context.Background(), tx)
+		defer t.finish()
+		if err := f(t); err != nil {
+			return err
+		}
+		writes = make(map[string][]byte, len(t.written))
+		for k := range t.written {
+			writes[k] = t.cache[k]
+		}
+		for k := range t.deleted {
+			deletes = append(deletes, k)
+		}
+		sort.Strings(deletes)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return writes, deletes, nil
+}
+
+//newTransaction creates an empty Transaction using the given redis transaction.
+func (d *Database) newTransaction(ctx context.Context, tx *redis.Tx) Transaction {
+	t := Transaction{}
+	t.d = d
+	t.ctx = ctx
+	t.tx = tx
+	t.cache = make(map[string][]byte)
+	t.written = make(map[string]struct{})
+	t.deleted = make(map[string]struct{})
+	t.ttl = make(map[string]time.Duration)
+	t.queued = make(map[string][]func(pipe redis.Pipeliner))
+	t.read = make(map[string]struct{})
+	t.seen = make(map[string][]byte)
+	t.versioned = make(map[string]versionedValue)
+	t.counters = make(map[hashField]int64)
+	t.hashWrites = make(map[hashField]struct{})
+	t.done = new(int32)
+	return t
+}
+
+//commit adds every change staged in the Transaction to the given pipeline.
+func (t Transaction) commit(pipe redis.Pipeliner) error {
+	for _, op := range t.operations() {
+		op(pipe)
+	}
+	return nil
+}
+
+//operations returns every change staged in the Transaction as a separate command to add to a pipeline.
+//Deletes come first, then writes, then queued commands.
+func (t Transaction) operations() []func(pipe redis.Pipeliner) {
+	var ops []func(pipe redis.Pipeliner)
+	for k := range t.deleted {
+		k := k
+		ops = append(ops, func(pipe redis.Pipeliner) {
+			pipe.Del(t.ctx, t.d.key(k))
+		})
+	}
+	for k := range t.written {
+		k := k
+		ops = append(ops, func(pipe redis.Pipeliner) {
+			pipe.Set(t.ctx, t.d.key(k), t.cache[k], t.ttl[k])
+		})
+	}
+	for _, commands := range t.queued {
+		ops = append(ops, commands...)
+	}
+	return ops
+}
+
+//changedKeys returns every key changed by the Transaction, in sorted order.
+func (t Transaction) changedKeys() []string {
+	keys := make(map[string]struct{})
+	for k := range t.deleted {
+		keys[k] = struct{}{}
+	}
+	for k := range t.written {
+		keys[k] = struct{}{}
+	}
+	for k := range t.queued {
+		keys[k] = struct{}{}
+	}
+	changed := make([]string, 0, len(keys))
+	for k := range keys {
+		changed = append(changed, k)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+//markRead records that the given keys have been read from redis by the Transaction.
+func (t Transaction) markRead(keys ...string) {
+	for _, k := range keys {
+		t.read[k] = struct{}{}
+	}
+}
+
+//Observer receives notifications about transactions, for example to export metrics.
+type Observer interface {
+	//OnRetry is called before a transaction is attempted again, with the number of the attempt about to start.
+	OnRetry(attempt int)
+	//OnCommit is called when a transaction commits, with the number of keys it changed and how long it took including retries.
+	OnCommit(keys int, d time.Duration)
+	//OnError is called when a transaction fails.
+	OnError(err error)
+}
+
+var observer Observer
+
+//SetObserver sets the Observer notified about every transaction, or nil to disable notifications.
+//It should be called before any transactions are executed.
+func SetObserver(o Observer) {
+	observer = o
+}
+
+var auditor func(read, written []string)
+
+//SetAuditor sets a function called after every transaction commits with the keys it read and the keys it changed, or nil to disable it.
+//Both are sorted and given without any Database prefix. A key is only reported as read if its value was fetched from redis, rather than from earlier writes in the same transaction.
+//It should be called before any transactions are executed.
+func SetAuditor(f func(read, written []string)) {
+	auditor = f
+}
+
+//Watch makes the transaction retry if any of the given keys change before it commits, without reading them.
+//This allows a transaction to depend on keys it does not otherwise use, such as a version number.
+//It has no effect in a transaction created by View.
+func (t Transaction) Watch(keys ...string) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return t.watch(keys...)
+}
+
+//watch adds the given keys to the set which abort the transaction if they change before commit.
+func (t Transaction) watch(keys ...string) error {
+	if t.readOnly || t.unwatched {
+		return nil
+	}
+	return t.tx.Watch(t.ctx, t.d.keys(keys)...).Err()
+}
+
+//ErrInvalidTransaction is returned when a Transaction is used outside of the function it was passed to,
+//such as a zero Transaction, or one kept after the attempt it belonged to has finished.
+var ErrInvalidTransaction = errors.New("database: transaction used outside of its function")
+
+//usable returns an error if the Transaction cannot currently be used.
+func (t Transaction) usable() error {
+	if t.d == nil || t.done == nil || atomic.LoadInt32(t.done) != 0 {
+		return ErrInvalidTransaction
+	}
+	return t.ctx.Err()
+}
+
+//finish marks the Transaction as finished, so that any copies of it kept by the caller can no longer be used.
+func (t Transaction) finish() {
+	atomic.StoreInt32(t.done, 1)
+}
+
+//writable returns an error if the Transaction cannot currently make changes.
+func (t Transaction) writable() error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	return nil
 }
 
 //Exists checks for the existence of a key in the database.
+//The key is watched, so the transaction is retried if it is created or deleted by another process before commit.
 func (t Transaction) Exists(key string) bool {
+	if t.usable() != nil {
+		return false
+	}
 	if _, ok := t.cache[key]; !ok {
-		if err := t.tx.Watch(key).Err(); err != nil {
+		if _, ok := t.deleted[key]; ok {
 			return false
 		}
-		return true
+		if err := t.watch(key); err != nil {
+			return false
+		}
+		t.markRead(key)
+		n, err := t.tx.Exists(t.ctx, t.d.key(key)).Result()
+		return err == nil && n > 0
 	}
 	return true
 }
 
 //Read reads the given key into the given interface, which should be a pointer.
+//The stored bytes are cached for the rest of the transaction, but they are decoded again on every call,
+//so a value needed several times in one transaction is best read once and reused.
+//Each call decodes with a new decoder, so the result never depends on earlier reads,
+//but the GobCodec leaves fields which are zero in the stored value untouched, so read into a zero value rather than reusing one.
 func (t Transaction) Read(key string, value interface{}) error {
-	if _, ok := t.cache[key]; !ok {
-		if err := t.tx.Watch(key).Err(); err != nil {
-			return err
+	if err := t.usable(); err != nil {
+		return err
+	}
+	data, err := t.fetch(key)
+	if err != nil {
+		return err
+	}
+	return t.d.decode(key, data, value)
+}
+
+//ReadFresh reads the given key into the given interface like Read, but always fetches it from redis again instead of using the cache.
+//Changes staged earlier in the same transaction are not included, since they have not been committed; otherwise the fresh value replaces the cached one.
+//The key is watched again, but that does not reset the watch: if it changed after it was first read or watched,
+//the transaction is still retried when it commits. Its main use is re-reading keys read with ReadSnapshot or ReadMultiNoWatch.
+func (t Transaction) ReadFresh(key string, value interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	data, err := t.tx.Get(t.ctx, t.d.key(key)).Bytes()
+	_, written := t.written[key]
+	_, deleted := t.deleted[key]
+	_, queued := t.queued[key]
+	staged := written || deleted || queued
+	if err == redis.Nil {
+		t.seen[key] = nil
+		if !staged {
+			delete(t.cache, key)
+		}
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	t.seen[key] = data
+	if !staged {
+		t.cache[key] = data
+	}
+	return t.d.decode(key, data, value)
+}
+
+//ReadOrDefault reads the given key into the given value like Read, but if the key does not exist it sets value to defaultValue and returns nil.
+//The default must be assignable to the value pointed to, or be a pointer to such a value.
+//The key is watched even when it is missing, so the transaction is retried if another process creates it before commit.
+func (t Transaction) ReadOrDefault(key string, value interface{}, defaultValue interface{}) error {
+	err := t.Read(key, value)
+	if err != ErrNotFound {
+		return err
+	}
+	target := reflect.ValueOf(value)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return errors.New("database: value must be a non nil pointer")
+	}
+	target = target.Elem()
+	def := reflect.ValueOf(defaultValue)
+	if !def.IsValid() {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	if !def.Type().AssignableTo(target.Type()) && def.Kind() == reflect.Ptr && !def.IsNil() {
+		def = def.Elem()
+	}
+	if !def.Type().AssignableTo(target.Type()) {
+		return fmt.Errorf("database: default of type %T cannot be assigned to %s", defaultValue, target.Type())
+	}
+	target.Set(def)
+	return nil
+}
+
+//fetch returns the stored form of the given key, watching it and caching it for the rest of the transaction.
+func (t Transaction) fetch(key string) ([]byte, error) {
+	if data, ok := t.cache[key]; ok {
+		return data, nil
+	}
+	if _, ok := t.deleted[key]; ok {
+		return nil, ErrNotFound
+	}
+	if err := t.watch(key); err != nil {
+		return nil, err
+	}
+	t.markRead(key)
+	data, err := t.tx.Get(t.ctx, t.d.key(key)).Bytes()
+	if err == redis.Nil {
+		t.seen[key] = nil
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.cache[key] = data
+	t.seen[key] = data
+	return data, nil
+}
+
+//ReadMulti reads each of the given keys into the corresponding interface, which should be a pointer, using a single request.
+//Keys which are not found are returned, and their corresponding interfaces are left untouched.
+func (t Transaction) ReadMulti(keys []string, values []interface{}) (missing []string, err error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	if len(keys) != len(values) {
+		return nil, errors.New("database: ReadMulti requires one value per key")
+	}
+	if err := t.fetchMulti(keys); err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		data, ok := t.cache[k]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		if err := t.d.decode(k, data, values[i]); err != nil {
+			return missing, err
 		}
-		value, err := t.tx.Get(key).Result()
+	}
+	return missing, nil
+}
+
+//ReadMultiNoWatch is like ReadMulti, but does not watch the keys it fetches, so changes to them do not cause the transaction to retry.
+//Values read this way are not protected by optimistic locking, and should only be used for data which does not change,
+//such as reference data, and never to decide what the transaction writes. They are not cached,
+//so a later Read of the same key fetches and watches it, but changes staged earlier in the transaction are still seen.
+func (t Transaction) ReadMultiNoWatch(keys []string, values []interface{}) (missing []string, err error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	if len(keys) != len(values) {
+		return nil, errors.New("database: ReadMultiNoWatch requires one value per key")
+	}
+	found := make(map[string][]byte, len(keys))
+	var fetch []string
+	for _, k := range keys {
+		if data, ok := t.cache[k]; ok {
+			found[k] = data
+		} else if _, ok := t.deleted[k]; !ok {
+			fetch = append(fetch, k)
+		}
+	}
+	if len(fetch) > 0 {
+		t.markRead(fetch...)
+		results, err := t.tx.MGet(t.ctx, t.d.keys(fetch)...).Result()
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for i, r := range results {
+			if s, ok := r.(string); ok {
+				found[fetch[i]] = []byte(s)
+			}
+		}
+	}
+	for i, k := range keys {
+		data, ok := found[k]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		if err := t.d.decode(k, data, values[i]); err != nil {
+			return missing, err
 		}
-		t.cache[key] = value
 	}
-	return gob.NewDecoder(bytes.NewBufferString(t.cache[key])).Decode(value)
+	return missing, nil
+}
+
+//ReadSnapshot reads the given key into the given interface like Read, but without watching it, so the transaction is
+//not retried if another process changes it before commit. The value is only guaranteed to be the one stored at the moment
+//it was read, which is weaker than Read, whose value is guaranteed to still be stored when the transaction commits.
+//It suits transactions which read many keys for context, where watching them all would cause constant retries,
+//but the value should not be used to decide what the transaction writes. It behaves like ReadMultiNoWatch for a single key.
+func (t Transaction) ReadSnapshot(key string, value interface{}) error {
+	missing, err := t.ReadMultiNoWatch([]string{key}, []interface{}{value})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+//fetchMulti watches and caches each of the given keys which is not already cached, using a single MGET.
+//Keys which do not exist are left out of the cache.
+func (t Transaction) fetchMulti(keys []string) error {
+	var fetch []string
+	for _, k := range keys {
+		_, deleted := t.deleted[k]
+		_, cached := t.cache[k]
+		if !deleted && !cached {
+			fetch = append(fetch, k)
+		}
+	}
+	if len(fetch) == 0 {
+		return nil
+	}
+	if err := t.watch(fetch...); err != nil {
+		return err
+	}
+	t.markRead(fetch...)
+	results, err := t.tx.MGet(t.ctx, t.d.keys(fetch)...).Result()
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		t.seen[fetch[i]] = nil
+		if s, ok := r.(string); ok {
+			t.cache[fetch[i]] = []byte(s)
+			t.seen[fetch[i]] = t.cache[fetch[i]]
+		}
+	}
+	return nil
+}
+
+//ExistsMulti checks for the existence of each of the given keys, and returns which are present.
+//The values of the keys are fetched with a single MGET and cached, so reading them later in the transaction does not fetch them again.
+//MGET does not return lists, hashes, sets or sorted sets, so any keys it did not find are checked again with EXISTS in a single pipeline,
+//and like Exists, ExistsMulti reports keys of every type.
+func (t Transaction) ExistsMulti(keys []string) (map[string]bool, error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	if err := t.fetchMulti(keys); err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(keys))
+	var check []string
+	for _, k := range keys {
+		_, present[k] = t.cache[k]
+		_, deleted := t.deleted[k]
+		if !present[k] && !deleted {
+			check = append(check, k)
+		}
+	}
+	if len(check) == 0 {
+		return present, nil
+	}
+	exists := make([]*redis.IntCmd, len(check))
+	_, err := t.tx.Pipelined(t.ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range check {
+			exists[i] = pipe.Exists(t.ctx, t.d.key(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range check {
+		present[k] = exists[i].Val() > 0
+	}
+	return present, nil
 }
 
 //Write writes the given data into the database at the given key.
+//Writing nil, or a nil pointer, returns ErrNilValue; use Delete to remove a key.
 func (t Transaction) Write(key string, value interface{}) error {
-	buffer := bytes.NewBuffer(nil)
-	encoder := gob.NewEncoder(buffer)
-	err := encoder.Encode(value)
+	return t.WriteWithTTL(key, value, 0)
+}
+
+//WriteWithTTL writes the given data into the database at the given key, which will expire after the given duration.
+//A duration of zero means the key never expires.
+//If a key is written more than once in the same transaction, the last duration given is used.
+func (t Transaction) WriteWithTTL(key string, value interface{}, ttl time.Duration) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	data, err := t.d.encode(key, value)
 	if err != nil {
 		return err
 	}
-	t.cache[key] = buffer.String()
+	t.stage(key, data, ttl)
+	return nil
+}
+
+//WriteMulti writes each of the given values into the database at its key.
+//Every value is encoded before any are staged, so if one fails to encode the transaction is left unchanged,
+//and the error identifies the offending key.
+func (t Transaction) WriteMulti(values map[string]interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	encoded := make(map[string][]byte, len(values))
+	for k, v := range values {
+		data, err := t.d.encode(k, v)
+		if err != nil {
+			return err
+		}
+		encoded[k] = data
+	}
+	for k, data := range encoded {
+		t.stage(k, data, 0)
+	}
+	return nil
+}
+
+//stage records that the given data should be written at the given key when the transaction commits.
+func (t Transaction) stage(key string, data []byte, ttl time.Duration) {
+	t.cache[key] = data
 	t.written[key] = struct{}{}
+	t.ttl[key] = ttl
+	delete(t.deleted, key)
+	delete(t.queued, key)
+	delete(t.versioned, key)
+}
+
+//Delete removes the given key from the database.
+//Reading the key later in the same transaction returns ErrNotFound.
+func (t Transaction) Delete(key string) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	delete(t.cache, key)
+	delete(t.written, key)
+	delete(t.ttl, key)
+	delete(t.queued, key)
+	delete(t.versioned, key)
+	for f := range t.counters {
+		if f.key == key {
+			delete(t.counters, f)
+		}
+	}
+	for f := range t.hashWrites {
+		if f.key == key {
+			delete(t.hashWrites, f)
+		}
+	}
+	t.deleted[key] = struct{}{}
 	return nil
 }
+
+//ReadAndDelete reads the given key into the given interface, which should be a pointer, then deletes it when the transaction commits.
+//It returns ErrNotFound if the key does not exist, and leaves the key in place if the value cannot be decoded.
+//The key is watched, so if another transaction reads and deletes it at the same time only one of them commits,
+//which makes it suitable for values which must only be used once, such as password reset tokens.
+//Reading the key later in the same transaction returns ErrNotFound.
+func (t Transaction) ReadAndDelete(key string, value interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	data, err := t.fetch(key)
+	if err != nil {
+		return err
+	}
+	if err := t.d.decode(key, data, value); err != nil {
+		return err
+	}
+	return t.Delete(key)
+}
+
+//Increment adds delta to the integer stored at the given key, and returns the resulting value.
+//Missing keys are treated as zero. The increment is applied with INCRBY when the transaction commits.
+//Counters are stored as plain integers rather than through the Codec, so they cannot be used with Read,
+//and Increment returns an error for a key which has been written with Write in the same transaction.
+//A key written with WriteInt64 in the same transaction can be incremented, in which case the sum is written instead.
+//A later Write or Delete of the key in the same transaction discards any pending increments.
+func (t Transaction) Increment(key string, delta int64) (int64, error) {
+	if err := t.writable(); err != nil {
+		return 0, err
+	}
+	if _, ok := t.written[key]; ok {
+		current, err := strconv.ParseInt(string(t.cache[key]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("database: cannot increment key %q, which holds a value which is not an integer", key)
+		}
+		current += delta
+		t.stage(key, []byte(strconv.FormatInt(current, 10)), t.ttl[key])
+		return current, nil
+	}
+	var current int64
+	data, err := t.fetch(key)
+	if err != nil && err != ErrNotFound {
+		return 0, err
+	}
+	if err == nil {
+		current, err = strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("database: cannot increment key %q: %v", key, err)
+		}
+	}
+	current += delta
+	t.cache[key] = []byte(strconv.FormatInt(current, 10))
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.IncrBy(t.ctx, t.d.key(key), delta)
+	})
+	return current, nil
+}
+
+//CompareAndSwap writes value at the given key only if the key currently holds expected, and reports whether it did.
+//Values are compared by their encoded form, so types which do not encode deterministically (such as maps with gob) may not compare equal.
+//Values written with WriteCodec are compared using the Codec they were written with,
+//and values encrypted by an EncryptingCodec are decrypted before they are compared.
+//If expected is nil, the swap only happens if the key does not exist; otherwise a missing key never matches.
+//Because the key is watched, the transaction is retried if another process changes it before commit.
+func (t Transaction) CompareAndSwap(key string, expected, value interface{}) (bool, error) {
+	if err := t.writable(); err != nil {
+		return false, err
+	}
+	current, err := t.fetch(key)
+	if err != nil && err != ErrNotFound {
+		return false, err
+	}
+	if (err == ErrNotFound) != (expected == nil) {
+		return false, nil
+	}
+	if expected != nil {
+		equal, err := t.d.holds(key, current, expected)
+		if err != nil || !equal {
+			return false, err
+		}
+	}
+	return true, t.Write(key, value)
+}
+
+//holds reports whether the given data, stored at the given key, is the encoded form of the given value.
+//The value is encoded with the Codec the data was written with, without compression or encryption,
+//and the data is decompressed, untagged and decrypted to match, since encrypting the same value twice gives different results.
+func (d *Database) holds(key string, data []byte, value interface{}) (bool, error) {
+	data, err := decompress(data)
+	if err != nil {
+		return false, &DecodeError{Key: key, Err: err}
+	}
+	c := d.encoding()
+	id, data, tagged := splitCodec(data)
+	if tagged {
+		if c, err = taggedCodec(id); err != nil {
+			return false, &DecodeError{Key: key, Err: err}
+		}
+	}
+	if e, ok := c.(*EncryptingCodec); ok {
+		if data, err = e.decrypt(data); err != nil {
+			return false, &DecodeError{Key: key, Err: err}
+		}
+		c = e.codec
+	}
+	expected, err := c.Marshal(value)
+	if err != nil {
+		return false, encodeError(key, c, err)
+	}
+	return bytes.Equal(expected, data), nil
+}
+
+//Update reads the value at the given key into value, which should be a pointer, calls mutate to change it in place, then writes it back.
+//If the key does not exist, value is set to its zero value before mutate is called, and created is true.
+//Value is always reset to zero before reading, so fields left over from an earlier attempt of the transaction do not leak into it.
+//If mutate returns an error, nothing is written and the error is returned.
+//Because the key is watched, the transaction is retried if another process changes it before commit.
+func (t Transaction) Update(key string, value interface{}, mutate func() error) (created bool, err error) {
+	if err := t.writable(); err != nil {
+		return false, err
+	}
+	target := reflect.ValueOf(value)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return false, errors.New("database: value must be a non nil pointer")
+	}
+	target.Elem().Set(reflect.Zero(target.Elem().Type()))
+	err = t.Read(key, value)
+	if err == ErrNotFound {
+		created = true
+	} else if err != nil {
+		return false, err
+	}
+	if err := mutate(); err != nil {
+		return false, err
+	}
+	return created, t.Write(key, value)
+}
+
+//ReadAndWrite reads the value at the given key into old, which should be a pointer, and then writes newValue at the key.
+//If the key did not exist, old is left untouched and ErrNotFound is returned, but newValue is still written.
+//Because the key is watched, the transaction is retried if another process changes it before commit.
+func (t Transaction) ReadAndWrite(key string, newValue interface{}, old interface{}) error {
+	readErr := t.Read(key, old)
+	if readErr != nil && readErr != ErrNotFound {
+		return readErr
+	}
+	if err := t.Write(key, newValue); err != nil {
+		return err
+	}
+	return readErr
+}
+
+//WriteIfAbsent writes the given value at the given key only if the key does not already exist, and reports whether it did.
+//Unlike SETNX, the check and the write do not happen in one command: the key is watched when it is checked,
+//and the write is staged until commit, so if another process creates the key in between the transaction is retried
+//and the check is made again.
+func (t Transaction) WriteIfAbsent(key string, value interface{}) (bool, error) {
+	if err := t.writable(); err != nil {
+		return false, err
+	}
+	_, err := t.fetch(key)
+	if err == nil {
+		return false, nil
+	}
+	if err != ErrNotFound {
+		return false, err
+	}
+	return true, t.Write(key, value)
+}
+
+//Copy writes the value stored at src to dst as well, replacing any value at dst.
+//Both keys are watched, and the copy is staged like a Write, so reading dst later in the same transaction returns the copied value.
+//It returns ErrNotFound if src does not exist. The copy only has an expiry if src was written with one earlier in the same transaction.
+func (t Transaction) Copy(src, dst string) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	data, err := t.fetch(src)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+	if err := t.watch(dst); err != nil {
+		return err
+	}
+	t.stage(dst, data, t.ttl[src])
+	return nil
+}
+
+//Rename moves the value stored at src to dst, replacing any value at dst.
+//It is staged as a Copy followed by a Delete of src, so reading dst later in the same transaction returns the moved value,
+//and reading src returns ErrNotFound.
+func (t Transaction) Rename(src, dst string) error {
+	if err := t.Copy(src, dst); err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+	return t.Delete(src)
+}
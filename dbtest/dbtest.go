@@ -0,0 +1,179 @@
+//Package dbtest provides an in-memory fake of database.Store for unit tests.
+//
+//Code which accepts a database.Store rather than calling the package level functions
+//can be given database.Default() in production and dbtest.New() in tests:
+//
+//	func CreateUser(s database.Store, u User) error {
+//		return s.Transact(func(t database.Tx) error {
+//			return t.Write("user:"+u.Name, u)
+//		})
+//	}
+//
+//The fake copies values through a database.Codec, and retries transactions which conflict
+//in the same way as the real database, so it exercises the same caller logic.
+package dbtest //import "github.com/clayts/database/dbtest"
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/clayts/database"
+)
+
+//ErrConflict is returned when a transaction still conflicts after every attempt.
+//Like the error returned by the real database, it matches database.ErrRetriesExhausted with errors.Is.
+var ErrConflict = fmt.Errorf("dbtest: transaction conflicted: %w", database.ErrRetriesExhausted)
+
+//Store is an in-memory implementation of database.Store.
+type Store struct {
+	//Codec is used to copy values in and out of the store.
+	Codec database.Codec
+	//MaxRetries is the number of times a transaction is attempted before giving up.
+	MaxRetries int
+
+	mu        sync.Mutex
+	data      map[string][]byte
+	versions  map[string]uint64
+	version   uint64
+	conflicts int
+}
+
+//New creates an empty Store which encodes values with gob.
+func New() *Store {
+	return &Store{
+		Codec:      database.GobCodec{},
+		MaxRetries: 3,
+		data:       make(map[string][]byte),
+		versions:   make(map[string]uint64),
+	}
+}
+
+//Conflict makes the next n transaction attempts fail to commit,
+//as though another process had changed a key they read, so that retry behaviour can be tested.
+func (s *Store) Conflict(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conflicts = n
+}
+
+//Read reads the given key into the given interface outside of a transaction, for making assertions in tests.
+func (s *Store) Read(key string, value interface{}) error {
+	s.mu.Lock()
+	data, ok := s.data[key]
+	s.mu.Unlock()
+	if !ok {
+		return database.ErrNotFound
+	}
+	return s.Codec.Unmarshal(data, value)
+}
+
+//Transact executes the given function as a transaction.
+//As with the real database, the function may be run several times if a conflict occurs,
+//and if it returns an error no changes are made.
+func (s *Store) Transact(f func(t database.Tx) error) error {
+	for i := 0; i < s.MaxRetries; i++ {
+		t := &tx{
+			s:       s,
+			read:    make(map[string]uint64),
+			written: make(map[string][]byte),
+			deleted: make(map[string]struct{}),
+		}
+		if err := f(t); err != nil {
+			return err
+		}
+		if s.commit(t) {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+//commit applies the changes staged in t, unless a key it read has changed since.
+func (s *Store) commit(t *tx) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflicts > 0 {
+		s.conflicts--
+		return false
+	}
+	for k, v := range t.read {
+		if s.versions[k] != v {
+			return false
+		}
+	}
+	for k := range t.deleted {
+		delete(s.data, k)
+		s.bump(k)
+	}
+	for k, data := range t.written {
+		s.data[k] = data
+		s.bump(k)
+	}
+	return true
+}
+
+//bump records that the given key has changed.
+func (s *Store) bump(key string) {
+	s.version++
+	s.versions[key] = s.version
+}
+
+//tx is the fake's implementation of database.Tx.
+type tx struct {
+	s       *Store
+	read    map[string]uint64
+	written map[string][]byte
+	deleted map[string]struct{}
+}
+
+//fetch returns the current data at the given key, watching it for conflicts.
+func (t *tx) fetch(key string) ([]byte, bool) {
+	if data, ok := t.written[key]; ok {
+		return data, true
+	}
+	if _, ok := t.deleted[key]; ok {
+		return nil, false
+	}
+	t.s.mu.Lock()
+	defer t.s.mu.Unlock()
+	if _, ok := t.read[key]; !ok {
+		t.read[key] = t.s.versions[key]
+	}
+	data, ok := t.s.data[key]
+	return data, ok
+}
+
+func (t *tx) Exists(key string) bool {
+	_, ok := t.fetch(key)
+	return ok
+}
+
+func (t *tx) Read(key string, value interface{}) error {
+	data, ok := t.fetch(key)
+	if !ok {
+		return database.ErrNotFound
+	}
+	return t.s.Codec.Unmarshal(data, value)
+}
+
+func (t *tx) Write(key string, value interface{}) error {
+	//Like the real database, nil values are rejected before reaching the Codec.
+	if v := reflect.ValueOf(value); value == nil || v.Kind() == reflect.Ptr && v.IsNil() {
+		return fmt.Errorf("%w at key %q", database.ErrNilValue, key)
+	}
+	data, err := t.s.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.written[key] = data
+	delete(t.deleted, key)
+	return nil
+}
+
+func (t *tx) Delete(key string) error {
+	t.fetch(key)
+	delete(t.written, key)
+	t.deleted[key] = struct{}{}
+	return nil
+}
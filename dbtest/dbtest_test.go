@@ -0,0 +1,143 @@
+package dbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/clayts/database"
+)
+
+func TestTransactWritesAndDeletes(t *testing.T) {
+	s := New()
+	err := s.Transact(func(tx database.Tx) error {
+		if err := tx.Write("a", 1); err != nil {
+			return err
+		}
+		return tx.Write("b", 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Transact(func(tx database.Tx) error {
+		return tx.Delete("a")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.Read("a", &n); err != database.ErrNotFound {
+		t.Fatalf("expected a to be deleted, got %v", err)
+	}
+	if err := s.Read("b", &n); err != nil || n != 2 {
+		t.Fatalf("expected b to be 2, got %d, %v", n, err)
+	}
+}
+
+func TestTransactErrorMakesNoChanges(t *testing.T) {
+	s := New()
+	failure := errors.New("failure")
+	attempts := 0
+	err := s.Transact(func(tx database.Tx) error {
+		attempts++
+		if err := tx.Write("a", 1); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("expected the function's error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt, got %d", attempts)
+	}
+	var n int
+	if err := s.Read("a", &n); err != database.ErrNotFound {
+		t.Fatalf("expected no changes to be made, got %d, %v", n, err)
+	}
+}
+
+//TestTransactRetriesConflict changes a key from another transaction after it has been read,
+//and checks that the first transaction is retried with the new value rather than overwriting it.
+func TestTransactRetriesConflict(t *testing.T) {
+	s := New()
+	attempts := 0
+	err := s.Transact(func(tx database.Tx) error {
+		attempts++
+		var n int
+		if err := tx.Read("counter", &n); err != nil && err != database.ErrNotFound {
+			return err
+		}
+		if attempts == 1 {
+			if err := s.Transact(func(tx database.Tx) error { return tx.Write("counter", 10) }); err != nil {
+				return err
+			}
+		}
+		return tx.Write("counter", n+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+	var n int
+	if err := s.Read("counter", &n); err != nil || n != 11 {
+		t.Fatalf("expected counter to be 11, got %d, %v", n, err)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	s := New()
+	s.Conflict(2)
+	attempts := 0
+	err := s.Transact(func(tx database.Tx) error {
+		attempts++
+		return tx.Write("a", attempts)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	var n int
+	if err := s.Read("a", &n); err != nil || n != 3 {
+		t.Fatalf("expected the last attempt to be committed, got %d, %v", n, err)
+	}
+}
+
+func TestConflictExhaustsRetries(t *testing.T) {
+	s := New()
+	s.Conflict(s.MaxRetries)
+	attempts := 0
+	err := s.Transact(func(tx database.Tx) error {
+		attempts++
+		return tx.Write("a", 1)
+	})
+	if err != ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if !errors.Is(err, database.ErrRetriesExhausted) {
+		t.Fatal("expected ErrConflict to match database.ErrRetriesExhausted")
+	}
+	if attempts != s.MaxRetries {
+		t.Fatalf("expected %d attempts, got %d", s.MaxRetries, attempts)
+	}
+	var n int
+	if err := s.Read("a", &n); err != database.ErrNotFound {
+		t.Fatalf("expected no changes to be made, got %d, %v", n, err)
+	}
+}
+
+func TestWriteRejectsNil(t *testing.T) {
+	s := New()
+	var nilPointer *int
+	for _, value := range []interface{}{nil, nilPointer} {
+		err := s.Transact(func(tx database.Tx) error {
+			return tx.Write("a", value)
+		})
+		if !errors.Is(err, database.ErrNilValue) {
+			t.Fatalf("writing %#v: expected an error matching ErrNilValue, got %v", value, err)
+		}
+	}
+}
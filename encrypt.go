@@ -0,0 +1,99 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+//encryptedVersion is the first byte of every value encrypted by EncryptingCodec, identifying the format of the rest.
+const encryptedVersion = 1
+
+//ErrDecrypt is returned when a value cannot be decrypted with any of the keys of an EncryptingCodec.
+var ErrDecrypt = errors.New("database: cannot decrypt value with any key")
+
+//ErrEncryptedMember is returned when adding, finding or removing a set or sorted set member with an EncryptingCodec,
+//since encrypted members would never compare equal.
+var ErrEncryptedMember = errors.New("database: set members cannot be encrypted")
+
+//EncryptingCodec encrypts the output of another Codec with AES-GCM, so that values are encrypted before they leave the process.
+//Each value is stored as a version byte, a random nonce, and the sealed data, which is also authenticated,
+//so values which have been tampered with or were encrypted with an unknown key fail to decode with ErrDecrypt.
+//Compression is applied after the Codec, so it cannot shrink encrypted values and need not be enabled with it.
+//Since the nonce is random, the same value encrypts differently every time. CompareAndSwap decrypts the stored value to compare it,
+//but set and sorted set members are compared by redis itself, so they cannot be encrypted, and those methods return ErrEncryptedMember.
+type EncryptingCodec struct {
+	codec Codec
+	aeads []cipher.AEAD
+}
+
+//NewEncryptingCodec creates an EncryptingCodec which encodes values with the given Codec and encrypts them with the first of the given keys.
+//Every key is tried in turn when decrypting, so to rotate keys, put the new key first and keep the old ones until
+//every value has been rewritten. Keys must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+func NewEncryptingCodec(codec Codec, keys ...[]byte) (*EncryptingCodec, error) {
+	if codec == nil {
+		return nil, errors.New("database: EncryptingCodec requires a Codec")
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("database: EncryptingCodec requires at least one key")
+	}
+	c := &EncryptingCodec{codec: codec}
+	for i, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("database: invalid encryption key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+//Marshal encodes the given value with the underlying Codec, then encrypts it with the first key.
+func (c *EncryptingCodec) Marshal(value interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	aead := c.aeads[0]
+	header := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(data)+aead.Overhead())
+	header[0] = encryptedVersion
+	nonce := header[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, data, header[:1]), nil
+}
+
+//Unmarshal decrypts the given data with the first key which succeeds, then decodes it with the underlying Codec.
+func (c *EncryptingCodec) Unmarshal(data []byte, value interface{}) error {
+	plain, err := c.decrypt(data)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(plain, value)
+}
+
+//decrypt returns the output of the underlying Codec which the given data was encrypted from.
+func (c *EncryptingCodec) decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != encryptedVersion {
+		return nil, ErrDecrypt
+	}
+	for _, aead := range c.aeads {
+		if len(data) < 1+aead.NonceSize() {
+			continue
+		}
+		nonce := data[1 : 1+aead.NonceSize()]
+		plain, err := aead.Open(nil, nonce, data[1+aead.NonceSize():], data[:1])
+		if err != nil {
+			continue
+		}
+		return plain, nil
+	}
+	return nil, ErrDecrypt
+}
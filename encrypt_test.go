@@ -0,0 +1,132 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func mustEncryptingCodec(t *testing.T, keys ...[]byte) *EncryptingCodec {
+	t.Helper()
+	c, err := NewEncryptingCodec(GobCodec{}, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEncryptingCodecRoundTrip(t *testing.T) {
+	c := mustEncryptingCodec(t, testKey(1))
+	data, err := c.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("encrypted value contains the plaintext")
+	}
+	again, err := c.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, again) {
+		t.Fatal("encrypting the same value twice gave the same result")
+	}
+	var s string
+	if err := c.Unmarshal(data, &s); err != nil || s != "secret" {
+		t.Fatalf("decoded %q, %v", s, err)
+	}
+}
+
+func TestEncryptingCodecRotatedKey(t *testing.T) {
+	old := mustEncryptingCodec(t, testKey(1))
+	data, err := old.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := mustEncryptingCodec(t, testKey(2), testKey(1))
+	var s string
+	if err := rotated.Unmarshal(data, &s); err != nil || s != "secret" {
+		t.Fatalf("decoded %q, %v with the old key second", s, err)
+	}
+	fresh, err := rotated.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Unmarshal(fresh, &s); err != ErrDecrypt {
+		t.Fatalf("expected new values to be encrypted with the first key, got %v", err)
+	}
+}
+
+func TestEncryptingCodecRejectsBadData(t *testing.T) {
+	c := mustEncryptingCodec(t, testKey(1))
+	data, err := c.Marshal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	version := append([]byte(nil), data...)
+	version[0] = encryptedVersion + 1
+	cases := map[string][]byte{
+		"tampered":      tampered,
+		"wrong version": version,
+		"truncated":     data[:5],
+		"empty":         nil,
+	}
+	var s string
+	for name, d := range cases {
+		if err := c.Unmarshal(d, &s); err != ErrDecrypt {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+	other := mustEncryptingCodec(t, testKey(3))
+	if err := other.Unmarshal(data, &s); err != ErrDecrypt {
+		t.Errorf("unknown key: expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestNewEncryptingCodecErrors(t *testing.T) {
+	if _, err := NewEncryptingCodec(GobCodec{}, []byte("short")); err == nil {
+		t.Error("expected an error for an invalid key length")
+	}
+	if _, err := NewEncryptingCodec(GobCodec{}, testKey(1), make([]byte, 20)); err == nil {
+		t.Error("expected an error for an invalid second key")
+	}
+	if _, err := NewEncryptingCodec(GobCodec{}); err == nil {
+		t.Error("expected an error with no keys")
+	}
+	if _, err := NewEncryptingCodec(nil, testKey(1)); err == nil {
+		t.Error("expected an error with no Codec")
+	}
+	for _, n := range []int{16, 24, 32} {
+		if _, err := NewEncryptingCodec(GobCodec{}, make([]byte, n)); err != nil {
+			t.Errorf("%d byte key: %v", n, err)
+		}
+	}
+}
+
+func TestEncryptingCodecMembers(t *testing.T) {
+	d := &Database{codec: mustEncryptingCodec(t, testKey(1))}
+	if _, err := d.encodeMember("set", 1); !errors.Is(err, ErrEncryptedMember) {
+		t.Fatalf("expected ErrEncryptedMember, got %v", err)
+	}
+}
+
+//TestEncryptingCodecHolds checks the comparison used by CompareAndSwap, which must see through the random nonce.
+func TestEncryptingCodecHolds(t *testing.T) {
+	d := &Database{codec: mustEncryptingCodec(t, testKey(1))}
+	data, err := d.encode("k", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equal, err := d.holds("k", data, "secret"); err != nil || !equal {
+		t.Fatalf("expected the value to match, got %v, %v", equal, err)
+	}
+	if equal, err := d.holds("k", data, "other"); err != nil || equal {
+		t.Fatalf("expected a different value not to match, got %v, %v", equal, err)
+	}
+}
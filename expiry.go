@@ -0,0 +1,127 @@
+package database
+
+import (
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//NoExpiry is returned by TTL for a key which exists but does not expire.
+const NoExpiry time.Duration = -1
+
+//TTL returns how long the given key has left to live, NoExpiry if it does not expire, or ErrNotFound if it does not exist.
+//For a key written earlier in the same transaction, the duration it was written with is returned.
+func (t Transaction) TTL(key string) (time.Duration, error) {
+	if err := t.usable(); err != nil {
+		return 0, err
+	}
+	if _, ok := t.written[key]; ok {
+		if ttl := t.ttl[key]; ttl > 0 {
+			return ttl, nil
+		}
+		return NoExpiry, nil
+	}
+	if _, ok := t.cache[key]; !ok {
+		if _, ok := t.deleted[key]; ok {
+			return 0, ErrNotFound
+		}
+	}
+	if err := t.watch(key); err != nil {
+		return 0, err
+	}
+	t.markRead(key)
+	ttl, err := t.tx.PTTL(t.ctx, t.d.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	switch ttl {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return NoExpiry, nil
+	}
+	return ttl, nil
+}
+
+//Expire sets the given key to expire after the given duration, without rewriting its value.
+//The expiry is applied with PEXPIRE when the transaction commits, and has no effect if the key does not exist by then.
+//As with WriteWithTTL, a duration of zero means the key never expires, removing any existing expiry.
+//For a key written earlier in the same transaction, it replaces the duration the key was written with.
+func (t Transaction) Expire(key string, ttl time.Duration) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok {
+		t.ttl[key] = ttl
+		return nil
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		if ttl > 0 {
+			pipe.PExpire(t.ctx, t.d.key(key), ttl)
+		} else {
+			pipe.Persist(t.ctx, t.d.key(key))
+		}
+	})
+	return nil
+}
+
+//ExpireAt sets the given key to expire at the given time, without rewriting its value.
+//The expiry is applied with PEXPIREAT when the transaction commits, and has no effect if the key does not exist by then;
+//a time which has already passed by then deletes the key. The zero time means the key never expires, removing any existing expiry.
+//For a key written earlier in the same transaction, the time is converted to a duration which replaces the one the key was written with,
+//and a time which has already passed deletes the key.
+func (t Transaction) ExpireAt(key string, at time.Time) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok && !at.IsZero() {
+		ttl := time.Until(at)
+		if ttl <= 0 {
+			return t.Delete(key)
+		}
+		t.ttl[key] = ttl
+		return nil
+	}
+	if at.IsZero() {
+		return t.Expire(key, 0)
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.PExpireAt(t.ctx, t.d.key(key), at)
+	})
+	return nil
+}
+
+//ExpiryTime returns the time at which the given key expires, and whether it expires at all, or ErrNotFound if it does not exist.
+//For a key written earlier in the same transaction, the time is calculated from the duration it was written with.
+//It uses PEXPIRETIME, which requires redis 7.0 or later.
+func (t Transaction) ExpiryTime(key string) (time.Time, bool, error) {
+	if err := t.usable(); err != nil {
+		return time.Time{}, false, err
+	}
+	if _, ok := t.written[key]; ok {
+		if ttl := t.ttl[key]; ttl > 0 {
+			return time.Now().Add(ttl), true, nil
+		}
+		return time.Time{}, false, nil
+	}
+	if _, ok := t.cache[key]; !ok {
+		if _, ok := t.deleted[key]; ok {
+			return time.Time{}, false, ErrNotFound
+		}
+	}
+	if err := t.watch(key); err != nil {
+		return time.Time{}, false, err
+	}
+	t.markRead(key)
+	at, err := t.tx.PExpireTime(t.ctx, t.d.key(key)).Result()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	switch at {
+	case -2:
+		return time.Time{}, false, ErrNotFound
+	case -1:
+		return time.Time{}, false, nil
+	}
+	return time.Unix(0, int64(at)), true, nil
+}
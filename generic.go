@@ -0,0 +1,94 @@
+package database
+
+//Get reads the given key within the given Transaction and returns it as a T.
+func Get[T any](t Transaction, key string) (T, error) {
+	var value T
+	err := t.Read(key, &value)
+	return value, err
+}
+
+//Put writes the given T at the given key within the given Transaction.
+func Put[T any](t Transaction, key string, value T) error {
+	return t.Write(key, value)
+}
+
+//Do is like Execute, but the function also returns a value, which Do returns once the transaction has committed.
+//Since the function may be run several times, only the value returned by the run which committed is kept.
+func Do[T any](f func(t Transaction) (T, error)) (T, error) {
+	return DoIn(db, f)
+}
+
+//DoIn is the Database equivalent of Do.
+func DoIn[T any](d *Database, f func(t Transaction) (T, error)) (T, error) {
+	var result T
+	err := d.Execute(func(t Transaction) error {
+		r, err := f(t)
+		if err != nil {
+			return err
+		}
+		result = r
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+//Collection stores values of type T under keys made of a fixed prefix and an id.
+type Collection[T any] struct {
+	d      *Database
+	prefix string
+}
+
+//NewCollection creates a Collection on the default database storing values of type T under keys starting with the given prefix.
+//The default database is looked up on every call, so the Collection can be created before connecting.
+func NewCollection[T any](prefix string) *Collection[T] {
+	return &Collection[T]{prefix: prefix}
+}
+
+//NewCollectionIn is the Database equivalent of NewCollection.
+//Any prefix of the Database itself still applies, in front of the Collection's prefix.
+func NewCollectionIn[T any](d *Database, prefix string) *Collection[T] {
+	return &Collection[T]{d: d, prefix: prefix}
+}
+
+//database returns the Database used by the Collection.
+func (c *Collection[T]) database() *Database {
+	if c.d == nil {
+		return db
+	}
+	return c.d
+}
+
+//Get returns the value with the given id, or ErrNotFound.
+func (c *Collection[T]) Get(id string) (T, error) {
+	var value T
+	err := c.database().View(func(t Transaction) error {
+		return t.Read(c.prefix+id, &value)
+	})
+	return value, err
+}
+
+//Set stores the given value with the given id, replacing any existing value.
+func (c *Collection[T]) Set(id string, value T) error {
+	return c.database().Execute(func(t Transaction) error {
+		return Put(t, c.prefix+id, value)
+	})
+}
+
+//Delete removes the value with the given id. Deleting an id which does not exist is not an error.
+func (c *Collection[T]) Delete(id string) error {
+	return c.database().Execute(func(t Transaction) error {
+		return t.Delete(c.prefix + id)
+	})
+}
+
+//All returns every value in the Collection, in no particular order.
+//Like ScanInto, it does not run in a transaction, and values which fail to decode are reported together as a MultiError after the rest have been read.
+func (c *Collection[T]) All() ([]T, error) {
+	var values []T
+	err := c.database().ScanInto(escapePattern(c.prefix)+"*", &values)
+	return values, err
+}
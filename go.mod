@@ -1,8 +1,14 @@
 module github.com/clayts/database
 
-go 1.12
+go 1.18
 
 require (
 	github.com/clayts/insist v0.0.0-20200308054529-60e4ec38512b
-	github.com/go-redis/redis/v7 v7.2.0
+	github.com/redis/go-redis/v9 v9.7.0
+	google.golang.org/protobuf v1.33.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 )
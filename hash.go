@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//HWrite writes the given value into a field of the hash stored at the given key, creating the hash if it does not exist.
+//Only the field is rewritten, and the key is not watched, so transactions which update different fields of the same hash do not conflict.
+//Read the field with HRead first if the transaction should be retried when another process changes the hash.
+//The write is applied when the transaction commits, so HRead and HReadAll do not see it until then.
+func (t Transaction) HWrite(key, field string, value interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok {
+		return fmt.Errorf("database: cannot write a field of key %q, which holds an encoded value", key)
+	}
+	data, err := t.d.encode(key, value)
+	if err != nil {
+		return err
+	}
+	delete(t.counters, hashField{key, field})
+	t.hashWrites[hashField{key, field}] = struct{}{}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.HSet(t.ctx, t.d.key(key), field, data)
+	})
+	return nil
+}
+
+//HDelete removes a field from the hash stored at the given key when the transaction commits.
+func (t Transaction) HDelete(key, field string) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	t.counters[hashField{key, field}] = 0
+	delete(t.hashWrites, hashField{key, field})
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.HDel(t.ctx, t.d.key(key), field)
+	})
+	return nil
+}
+
+//hashField identifies a field of a hash.
+type hashField struct {
+	key, field string
+}
+
+//HIncrement adds delta to the integer stored in a field of the hash stored at the given key, and returns the resulting value.
+//Missing keys and fields are treated as zero. The increment is applied with HINCRBY when the transaction commits,
+//so only the field changes, rather than the whole hash being read and rewritten.
+//Unlike HWrite, the key is watched, so that the returned value is exact; the transaction is retried if another process changes the hash.
+//Counters are stored as plain integers rather than through the Codec, so they cannot be read with HRead or HReadAll;
+//call HIncrement with a delta of zero to read one. A field holding a value written with HWrite cannot be incremented,
+//whether it was written earlier in the same transaction or is already stored, and HIncrement returns an error before anything is committed.
+func (t Transaction) HIncrement(key, field string, delta int64) (int64, error) {
+	if err := t.writable(); err != nil {
+		return 0, err
+	}
+	if _, ok := t.written[key]; ok {
+		return 0, fmt.Errorf("database: cannot increment a field of key %q, which holds an encoded value", key)
+	}
+	f := hashField{key, field}
+	if _, ok := t.hashWrites[f]; ok {
+		return 0, fmt.Errorf("database: cannot increment field %q of key %q, which holds a value written with HWrite", field, key)
+	}
+	current, ok := t.counters[f]
+	if _, deleted := t.deleted[key]; !ok && !deleted {
+		if err := t.watch(key); err != nil {
+			return 0, err
+		}
+		t.markRead(key)
+		n, err := t.tx.HGet(t.ctx, t.d.key(key), field).Int64()
+		if err != nil && err != redis.Nil {
+			return 0, fmt.Errorf("database: cannot increment field %q of key %q: %w", field, key, err)
+		}
+		current = n
+	}
+	current += delta
+	t.counters[f] = current
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.HIncrBy(t.ctx, t.d.key(key), field, delta)
+	})
+	return current, nil
+}
+
+//HRead reads a field of the hash stored at the given key into the given interface, which should be a pointer.
+//It returns ErrNotFound if the key or field does not exist.
+//The field is read from the database, so changes staged earlier in the same transaction are not included.
+func (t Transaction) HRead(key, field string, value interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	data, err := t.tx.HGet(t.ctx, t.d.key(key), field).Bytes()
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return t.d.decode(key, data, value)
+}
+
+//HReadAll reads every field of the hash stored at the given key into out, which should be a pointer to a map with string keys.
+//Each field is decoded into a new value of the map's element type. A missing key reads as an empty hash.
+//The hash is read from the database, so changes staged earlier in the same transaction are not included.
+func (t Transaction) HReadAll(key string, out interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	m := reflect.ValueOf(out)
+	if m.Kind() != reflect.Ptr || m.Elem().Kind() != reflect.Map || m.Elem().Type().Key().Kind() != reflect.String {
+		return errors.New("database: out must be a pointer to a map with string keys")
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	fields, err := t.tx.HGetAll(t.ctx, t.d.key(key)).Result()
+	if err != nil {
+		return err
+	}
+	mapType := m.Elem().Type()
+	result := reflect.MakeMapWithSize(mapType, len(fields))
+	for field, data := range fields {
+		e := reflect.New(mapType.Elem())
+		if err := t.d.decode(key, []byte(data), e.Interface()); err != nil {
+			return err
+		}
+		result.SetMapIndex(reflect.ValueOf(field).Convert(mapType.Key()), e.Elem())
+	}
+	m.Elem().Set(result)
+	return nil
+}
+
+//HScan calls fn with the encoded value of every field of the hash stored at the given key, which can be decoded with HDecode.
+//Unlike HReadAll, the hash is read in batches with HSCAN, so large hashes neither block redis nor need to fit in memory.
+//HSCAN makes weak guarantees: a field may be passed to fn more than once, and fields added or removed during the scan may or may not be included.
+//If fn returns an error, the scan stops and the error is returned.
+//The hash is read from the database, so changes staged earlier in the same transaction are not included.
+func (t Transaction) HScan(key string, fn func(field string, value []byte) error) error {
+	return t.HScanMatch(key, "", 0, fn)
+}
+
+//HScanMatch is like HScan, but only calls fn for fields which match the given glob-style pattern, or every field if it is empty.
+//Count hints how many fields redis should look at in each batch, or redis's default of 10 if it is zero or less.
+//The pattern is applied by redis after each batch is read, so a selective pattern may return few fields per batch.
+func (t Transaction) HScanMatch(key, match string, count int64, fn func(field string, value []byte) error) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	if count < 0 {
+		count = 0
+	}
+	var cursor uint64
+	for {
+		pairs, next, err := t.tx.HScan(t.ctx, t.d.key(key), cursor, match, count).Result()
+		if err != nil {
+			return err
+		}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if err := fn(pairs[i], []byte(pairs[i+1])); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+//HDecode decodes the encoded value of a field of the hash stored at the given key, as passed to the function given to HScan,
+//into the given interface, which should be a pointer.
+func (t Transaction) HDecode(key string, data []byte, value interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	return t.d.decode(key, data, value)
+}
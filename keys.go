@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//ScanKeys calls fn for each key in the default database which matches the given pattern.
+//An empty pattern matches every key. Count is a hint for how many keys to fetch per SCAN call, or zero for the redis default.
+//SCAN may report the same key more than once, and keys changed during the scan may or may not be included.
+//If fn returns an error, scanning stops and the error is returned.
+func ScanKeys(pattern string, count int64, fn func(key string) error) error {
+	return db.ScanKeys(pattern, count, fn)
+}
+
+//Keys returns every key in the default database which matches the given pattern.
+//An empty pattern matches every key. For large databases prefer ScanKeys, which does not hold every key in memory.
+func Keys(pattern string) ([]string, error) {
+	return db.Keys(pattern)
+}
+
+//ScanKeys is the Database equivalent of the package level ScanKeys.
+//If the Database has a prefix, the pattern only matches keys with that prefix, and the prefix is removed before calling fn.
+func (d *Database) ScanKeys(pattern string, count int64, fn func(key string) error) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	if pattern == "" {
+		pattern = "*"
+	}
+	pattern = escapePattern(d.prefix) + pattern
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(context.Background(), cursor, pattern, count).Result()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := fn(strings.TrimPrefix(k, d.prefix)); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+//Keys is the Database equivalent of the package level Keys.
+func (d *Database) Keys(pattern string) ([]string, error) {
+	var keys []string
+	err := d.ScanKeys(pattern, 0, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+//Count returns the number of keys in the default database which match the given pattern, without fetching their values.
+func Count(pattern string) (int64, error) {
+	return db.Count(pattern)
+}
+
+//Count is the Database equivalent of the package level Count.
+//It iterates the whole keyspace with SCAN rather than KEYS, so it does not block redis, but it takes time proportional to the
+//total number of keys in the database, not just the matching ones. Keys are counted once even if SCAN reports them more than once,
+//which needs memory proportional to the number of matches. The count is exact for keys which exist throughout the scan,
+//while keys created or deleted during it may or may not be included.
+func (d *Database) Count(pattern string) (int64, error) {
+	seen := make(map[string]struct{})
+	err := d.ScanKeys(pattern, scanBatchSize, func(key string) error {
+		seen[key] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(seen)), nil
+}
+
+//FlushPattern deletes every key in the default database which matches the given pattern, leaving other keys intact.
+func FlushPattern(pattern string) error {
+	return db.FlushPattern(pattern)
+}
+
+//FlushPattern is the Database equivalent of the package level FlushPattern.
+//If the Database has a prefix, only keys with that prefix are matched.
+func (d *Database) FlushPattern(pattern string) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	var batch []string
+	err = d.ScanKeys(pattern, flushBatchSize, func(key string) error {
+		batch = append(batch, d.key(key))
+		if len(batch) < flushBatchSize {
+			return nil
+		}
+		err := client.Del(context.Background(), batch...).Err()
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return client.Del(context.Background(), batch...).Err()
+	}
+	return nil
+}
+
+//DeleteMany deletes the given keys from the default database outside of any transaction, and returns how many existed.
+func DeleteMany(keys ...string) (int64, error) {
+	return db.DeleteMany(keys...)
+}
+
+//DeleteMany is the Database equivalent of the package level DeleteMany.
+//Keys are deleted in pipelined batches, without watching them, so it is suited to bulk cleanup rather than to keys other processes are using.
+func (d *Database) DeleteMany(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	client, err := d.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer d.end(client)
+	var commands []*redis.IntCmd
+	_, err = client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for start := 0; start < len(keys); start += flushBatchSize {
+			end := start + flushBatchSize
+			if end > len(keys) {
+				end = len(keys)
+			}
+			commands = append(commands, pipe.Del(context.Background(), d.keys(keys[start:end])...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for _, c := range commands {
+		deleted += c.Val()
+	}
+	return deleted, nil
+}
+
+//flushBatchSize is the number of keys deleted per DEL when flushing by SCAN.
+const flushBatchSize = 500
+
+//ScanInto reads every key in the default database which matches the given pattern into out, which should be a pointer to a slice.
+func ScanInto(pattern string, out interface{}) error {
+	return db.ScanInto(pattern, out)
+}
+
+//ScanInto is the Database equivalent of the package level ScanInto.
+//Each value is decoded into a new element appended to the slice, in the order SCAN returns the keys.
+//It does not run in a transaction, so keys changed during the scan may or may not be included.
+//Values which fail to decode are skipped, and reported together as a MultiError after the rest have been read.
+func (d *Database) ScanInto(pattern string, out interface{}) error {
+	slice := reflect.ValueOf(out)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return errors.New("database: out must be a pointer to a slice")
+	}
+	elemType := slice.Elem().Type().Elem()
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	var failures MultiError
+	var batch []string
+	read := func() error {
+		results, err := client.MGet(context.Background(), d.keys(batch)...).Result()
+		if err != nil {
+			return err
+		}
+		for i, r := range results {
+			data, ok := r.(string)
+			if !ok {
+				continue
+			}
+			e := reflect.New(elemType)
+			if err := d.decode(batch[i], []byte(data), e.Interface()); err != nil {
+				failures = append(failures, err)
+				continue
+			}
+			slice.Elem().Set(reflect.Append(slice.Elem(), e.Elem()))
+		}
+		batch = batch[:0]
+		return nil
+	}
+	err = d.ScanKeys(pattern, scanBatchSize, func(key string) error {
+		batch = append(batch, key)
+		if len(batch) < scanBatchSize {
+			return nil
+		}
+		return read()
+	})
+	if err == nil && len(batch) > 0 {
+		err = read()
+	}
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+//scanBatchSize is the number of values fetched per MGET when reading by SCAN.
+const scanBatchSize = 500
+
+//MultiError collects several errors, such as one for each value which failed to decode.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	if len(m) == 0 {
+		return "database: no errors"
+	}
+	if len(m) == 1 {
+		return m[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", m[0], len(m)-1)
+}
+
+//Is reports whether any of the collected errors matches target, so that errors.Is can match any of them.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//As finds the first of the collected errors which matches target, so that errors.As can match any of them.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//Unwrap returns the collected errors, for code using Go 1.20 or later which walks error trees itself.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+//escapePattern escapes the glob characters in s, so that it only matches itself in a SCAN pattern.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
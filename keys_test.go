@@ -0,0 +1,24 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	if msg := (MultiError{}).Error(); msg != "database: no errors" {
+		t.Fatalf("empty MultiError gave %q", msg)
+	}
+	decodeErr := &DecodeError{Key: "k", Err: errors.New("bad data")}
+	err := error(MultiError{errors.New("first"), decodeErr, ErrNotFound})
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatal("errors.Is did not match a collected error")
+	}
+	var target *DecodeError
+	if !errors.As(err, &target) || target != decodeErr {
+		t.Fatal("errors.As did not find the collected DecodeError")
+	}
+	if errors.Is(err, ErrClosed) {
+		t.Fatal("errors.Is matched an error which was not collected")
+	}
+}
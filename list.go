@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//PushBack appends the given value to the list stored at the given key, creating the list if it does not exist.
+//Each element is encoded independently, so the list can grow without rewriting it.
+//A redis list holds at most 2^32-1 elements, each of which may be up to 512MB once encoded.
+//The push is applied when the transaction commits, so Range does not see it until then.
+//Deleting the key with Delete removes the whole list, including pushes staged earlier in the same transaction.
+func (t Transaction) PushBack(key string, value interface{}) error {
+	return t.push(key, value, false)
+}
+
+//PushFront prepends the given value to the list stored at the given key, in the same way as PushBack.
+func (t Transaction) PushFront(key string, value interface{}) error {
+	return t.push(key, value, true)
+}
+
+func (t Transaction) push(key string, value interface{}, front bool) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok {
+		return fmt.Errorf("database: cannot push to key %q, which holds an encoded value", key)
+	}
+	data, err := t.d.encode(key, value)
+	if err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		if front {
+			pipe.LPush(t.ctx, t.d.key(key), data)
+		} else {
+			pipe.RPush(t.ctx, t.d.key(key), data)
+		}
+	})
+	return nil
+}
+
+//Range reads the elements of the list stored at the given key from start to stop into out, which should be a pointer to a slice.
+//Both start and stop are inclusive, and negative indices count back from the end of the list, so Range(key, 0, -1, &out) reads the whole list.
+//A missing key reads as an empty list. The list is read from the database, so changes staged earlier in the same transaction are not included.
+func (t Transaction) Range(key string, start, stop int64, out interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	items, err := t.tx.LRange(t.ctx, t.d.key(key), start, stop).Result()
+	if err != nil {
+		return err
+	}
+	return t.d.decodeSlice(key, items, out)
+}
+
+//decodeSlice decodes each of the given items, stored at the given key, into a new element of the slice pointed to by out.
+func (d *Database) decodeSlice(key string, items []string, out interface{}) error {
+	slice := reflect.ValueOf(out)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return errors.New("database: out must be a pointer to a slice")
+	}
+	elemType := slice.Elem().Type().Elem()
+	result := reflect.MakeSlice(slice.Elem().Type(), 0, len(items))
+	for _, item := range items {
+		e := reflect.New(elemType)
+		if err := d.decode(key, []byte(item), e.Interface()); err != nil {
+			return err
+		}
+		result = reflect.Append(result, e.Elem())
+	}
+	slice.Elem().Set(result)
+	return nil
+}
+
+//PopBlocking removes the first element of the list stored at the given key in the default database and decodes it into out,
+//waiting up to timeout for one to be pushed if the list is empty.
+func PopBlocking(key string, timeout time.Duration, out interface{}) error {
+	return db.PopBlocking(key, timeout, out)
+}
+
+//PopBlocking is the Database equivalent of the package level PopBlocking.
+//It uses BLPOP, so elements added with PushBack are consumed in the order they were pushed, and each element is
+//delivered to only one consumer. It returns ErrNotFound if the timeout elapses first; a timeout of zero waits forever.
+//Blocking commands cannot run inside a watched transaction, so it is not part of any Transaction, and it holds
+//a connection from the pool while it waits. If the element fails to decode, it has still been removed from the list.
+func (d *Database) PopBlocking(key string, timeout time.Duration, out interface{}) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	result, err := client.BLPop(context.Background(), timeout, d.key(key)).Result()
+	if err == redis.Nil {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return d.decode(key, []byte(result[1]), out)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+//ErrLockNotHeld is returned when releasing a lock which has already expired, or been released.
+var ErrLockNotHeld = errors.New("database: lock not held")
+
+//releaseScript deletes a lock only if it still holds the token of the caller, so that a lock which expired and was acquired by another process is left alone.
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+//AcquireLock tries once to take the lock at the given key on the default database, which expires after the given ttl if it is not released.
+func AcquireLock(key string, ttl time.Duration) (release func() error, acquired bool, err error) {
+	return db.AcquireLock(key, ttl)
+}
+
+//AcquireLock is the Database equivalent of the package level AcquireLock.
+//The lock is taken with SET NX PX and a random token, and does not wait: if another process holds the lock, acquired is false.
+//Release deletes the lock only if it still holds the token, and returns ErrLockNotHeld if it has expired in the meantime,
+//in which case another process may have entered the critical section, so ttl should comfortably exceed the work done while holding it.
+//The lock key is not part of any Transaction, and should not be read or written with Execute.
+func (d *Database) AcquireLock(key string, ttl time.Duration) (release func() error, acquired bool, err error) {
+	if ttl <= 0 {
+		return nil, false, errors.New("database: lock ttl must be positive")
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(random)
+	client, err := d.begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer d.end(client)
+	acquired, err = client.SetNX(context.Background(), d.key(key), token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, err
+	}
+	release = func() error {
+		result, err := d.RunScript(releaseScript, []string{key}, token)
+		if err != nil {
+			return err
+		}
+		if n, ok := result.(int64); !ok || n == 0 {
+			return ErrLockNotHeld
+		}
+		return nil
+	}
+	return release, true, nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import "log"
+
+//Logger is used by the package to report noteworthy events, such as a transaction running out of retries.
+//A *log.Logger satisfies it.
+type Logger interface {
+	Println(v ...interface{})
+}
+
+//stdLogger writes to the standard logger.
+type stdLogger struct{}
+
+func (stdLogger) Println(v ...interface{}) {
+	log.Println(v...)
+}
+
+//discardLogger ignores everything written to it.
+type discardLogger struct{}
+
+func (discardLogger) Println(v ...interface{}) {}
+
+var logger Logger = stdLogger{}
+
+//SetLogger sets the Logger used by the package, which writes to the standard logger by default.
+//Passing nil silences the package.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = discardLogger{}
+	}
+	logger = l
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//WithPoolSize sets the maximum number of connections the Database keeps open to redis.
+func WithPoolSize(n int) Option {
+	return func(d *Database) {
+		d.conn.options.PoolSize = n
+	}
+}
+
+//WithMinIdleConns sets the number of idle connections the Database keeps open, so that bursts of work do not wait to dial.
+func WithMinIdleConns(n int) Option {
+	return func(d *Database) {
+		d.conn.options.MinIdleConns = n
+	}
+}
+
+//WithPoolTimeout sets how long an operation waits for a connection when every connection in the pool is busy.
+func WithPoolTimeout(timeout time.Duration) Option {
+	return func(d *Database) {
+		d.conn.options.PoolTimeout = timeout
+	}
+}
+
+//WithDialTimeout sets how long the Database waits when opening a new connection.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(d *Database) {
+		d.conn.options.DialTimeout = timeout
+	}
+}
+
+//WithReadTimeout sets how long the Database waits for redis to reply to a command.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(d *Database) {
+		d.conn.options.ReadTimeout = timeout
+	}
+}
+
+//WithWriteTimeout sets how long the Database waits when sending a command to redis.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(d *Database) {
+		d.conn.options.WriteTimeout = timeout
+	}
+}
+
+//WithTLSConfig connects to redis over TLS using the given configuration, for example to trust a private CA or present a client certificate.
+//It replaces the configuration ParseURL creates for rediss:// urls, and enables TLS for redis:// urls too.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(d *Database) {
+		d.conn.options.TLSConfig = config
+	}
+}
+
+//WithDB selects the logical redis database, overriding any number given in the url.
+func WithDB(n int) Option {
+	return func(d *Database) {
+		d.conn.options.DB = n
+	}
+}
+
+//SelectDB returns a new Database using the given logical redis database, with the same settings as this one.
+//It has its own connection pool, because redis selects the logical database per connection and a
+//transaction cannot switch database while keys are watched. Both Databases must be terminated separately.
+func (d *Database) SelectDB(n int) (*Database, error) {
+	options := *d.conn.options
+	options.DB = n
+	client := redis.NewClient(&options)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	derived := *d
+	derived.conn = &connection{options: &options}
+	derived.conn.install(client)
+	return &derived, nil
+}
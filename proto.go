@@ -0,0 +1,31 @@
+package database
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+//ProtoCodec encodes protobuf messages using their wire format, so that they can be read by services written in any language.
+//Every value written must be a proto.Message, and values must be read into a pointer to a message of the same type.
+//Messages need no registration, unlike types stored in interface fields with the GobCodec.
+//Use it for a whole Database with the Database's SetCodec, and keep the GobCodec for others.
+type ProtoCodec struct{}
+
+//Marshal encodes the given value, which must be a proto.Message.
+func (ProtoCodec) Marshal(value interface{}) ([]byte, error) {
+	m, ok := value.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("database: ProtoCodec cannot encode %T, which is not a proto.Message", value)
+	}
+	return proto.Marshal(m)
+}
+
+//Unmarshal decodes the given data into the given value, which must be a proto.Message.
+func (ProtoCodec) Unmarshal(data []byte, value interface{}) error {
+	m, ok := value.(proto.Message)
+	if !ok {
+		return fmt.Errorf("database: ProtoCodec cannot decode into %T, which is not a proto.Message", value)
+	}
+	return proto.Unmarshal(data, m)
+}
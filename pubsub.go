@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//Publish sends the given message to every subscriber of the given channel on the default database.
+func Publish(channel string, message interface{}) error {
+	return db.Publish(channel, message)
+}
+
+//Publish is the Database equivalent of the package level Publish.
+//The message is encoded with the Codec as an interface value, so with the GobCodec its concrete type must be registered with RegisterType.
+//If the Database has a prefix, it is added to the channel name, so that only subscribers using the same prefix receive the message.
+func (d *Database) Publish(channel string, message interface{}) error {
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	data, err := d.encode(channel, &message)
+	if err != nil {
+		return err
+	}
+	return client.Publish(context.Background(), d.key(channel), data).Err()
+}
+
+//Subscribe calls the given handler with every message published to the given channel on the default database, until cancel is called.
+func Subscribe(channel string, handler func(msg interface{}) error) (cancel func(), err error) {
+	return db.Subscribe(channel, handler)
+}
+
+//Subscribe is the Database equivalent of the package level Subscribe.
+//Messages are decoded with the Codec and passed to the handler one at a time from a separate goroutine.
+//Messages which fail to decode, and errors returned by the handler, are logged and the subscription carries on.
+//Messages published while there is no subscription are not delivered later.
+//Cancel stops the subscription and waits for the handler to return, so it must not be called from within the handler.
+//It may be called more than once, and should be called before the Database is terminated.
+func (d *Database) Subscribe(channel string, handler func(msg interface{}) error) (cancel func(), err error) {
+	client, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer d.end(client)
+	ctx := context.Background()
+	pubsub := client.Subscribe(ctx, d.key(channel))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	messages := pubsub.Channel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range messages {
+			var msg interface{}
+			if err := d.decode(channel, []byte(m.Payload), &msg); err != nil {
+				logger.Println("skipping message on channel", channel+":", err)
+				continue
+			}
+			if err := handler(msg); err != nil {
+				logger.Println("handler failed for message on channel", channel+":", err)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pubsub.Close()
+			<-done
+		})
+	}, nil
+}
+
+//ErrNotificationsDisabled is returned by OnExpired when redis is not configured to publish expiry events.
+var ErrNotificationsDisabled = errors.New("database: keyspace notifications for expired keys are disabled, set notify-keyspace-events to include K and x")
+
+//OnExpired calls the given handler with the name of every key matching the given pattern which expires in the default database, until cancel is called.
+func OnExpired(pattern string, handler func(key string)) (cancel func(), err error) {
+	return db.OnExpired(pattern, handler)
+}
+
+//OnExpired is the Database equivalent of the package level OnExpired.
+//It relies on redis keyspace notifications, which are off by default: the notify-keyspace-events setting must include
+//K and x (for example "Kx"), or OnExpired returns ErrNotificationsDisabled. If the setting cannot be read, for example
+//because CONFIG is disabled, the subscription is made anyway, and no events arrive unless notifications are enabled.
+//Redis publishes the event when it deletes the key, which may be some time after it expired if the key is not accessed,
+//and events are not delivered to a process which is not subscribed at the time.
+//If the Database has a prefix, only keys with that prefix are matched, and the prefix is removed before calling the handler.
+//Cancel behaves as it does for Subscribe.
+func (d *Database) OnExpired(pattern string, handler func(key string)) (cancel func(), err error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	client, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer d.end(client)
+	ctx := context.Background()
+	config, err := client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		logger.Println("could not check notify-keyspace-events, subscribing anyway:", err)
+	} else if events := config["notify-keyspace-events"]; !strings.Contains(events, "K") || !strings.ContainsAny(events, "xA") {
+		return nil, ErrNotificationsDisabled
+	}
+	channel := fmt.Sprintf("__keyspace@%d__:", d.conn.options.DB)
+	pubsub := client.PSubscribe(ctx, channel+escapePattern(d.prefix)+pattern)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	messages := pubsub.Channel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range messages {
+			if m.Payload != "expired" {
+				continue
+			}
+			handler(strings.TrimPrefix(strings.TrimPrefix(m.Channel, channel), d.prefix))
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pubsub.Close()
+			<-done
+		})
+	}, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//ReadRaw returns the bytes stored at the given key, without decoding them with the Codec or decompressing them.
+//The returned slice is shared with the transaction's cache and must not be modified.
+func (t Transaction) ReadRaw(key string) ([]byte, error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	return t.fetch(key)
+}
+
+//ErrNotJSON is returned by ReadJSON when the value was not written with the JSONCodec.
+var ErrNotJSON = errors.New("database: ReadJSON requires the JSONCodec")
+
+//ReadJSON returns the JSON stored at the given key without decoding it, so that it can be passed straight through to a client.
+//Only values written with the JSONCodec, either by a Database using it or by WriteCodec, are JSON, so it returns ErrNotJSON for any other value.
+//Compressed values are decompressed; otherwise the returned value is shared with the transaction's cache and must not be modified.
+func (t Transaction) ReadJSON(key string) (json.RawMessage, error) {
+	if err := t.usable(); err != nil {
+		return nil, err
+	}
+	data, err := t.ReadRaw(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err = decompress(data)
+	if err != nil {
+		return nil, &DecodeError{Key: key, Err: err}
+	}
+	id, untagged, tagged := splitCodec(data)
+	if tagged {
+		if _, ok := codecs[id].(JSONCodec); !ok {
+			return nil, ErrNotJSON
+		}
+		return json.RawMessage(untagged), nil
+	}
+	if _, ok := t.d.encoding().(JSONCodec); !ok {
+		return nil, ErrNotJSON
+	}
+	return json.RawMessage(data), nil
+}
+
+//Size returns the length in bytes of the value stored at the given key, as encoded and compressed, without fetching it.
+//This is the length of the stored string reported by STRLEN, not its full memory footprint in redis, which also includes the key and overheads.
+//The key is watched, and a value written earlier in the same transaction is measured without asking redis.
+func (t Transaction) Size(key string) (int64, error) {
+	if err := t.usable(); err != nil {
+		return 0, err
+	}
+	if data, ok := t.cache[key]; ok {
+		return int64(len(data)), nil
+	}
+	if _, ok := t.deleted[key]; ok {
+		return 0, ErrNotFound
+	}
+	if err := t.watch(key); err != nil {
+		return 0, err
+	}
+	t.markRead(key)
+	n, err := t.tx.StrLen(t.ctx, t.d.key(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 && !t.Exists(key) {
+		return 0, ErrNotFound
+	}
+	return n, nil
+}
+
+//WriteRaw writes the given bytes at the given key exactly as they are, without encoding them with the Codec or compressing them.
+//The data must not be modified until the transaction has finished.
+func (t Transaction) WriteRaw(key string, data []byte) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if err := checkSize(key, data); err != nil {
+		return err
+	}
+	t.stage(key, data, 0)
+	return nil
+}
+
+//WriteString writes the given string at the given key as plain text, so that it can be read by redis-cli and other programs.
+func (t Transaction) WriteString(key string, value string) error {
+	return t.WriteRaw(key, []byte(value))
+}
+
+//ReadString reads a string written by WriteString.
+func (t Transaction) ReadString(key string) (string, error) {
+	data, err := t.ReadRaw(key)
+	return string(data), err
+}
+
+//WriteInt64 writes the given integer at the given key in decimal, which is the form used by Increment.
+func (t Transaction) WriteInt64(key string, value int64) error {
+	return t.WriteRaw(key, []byte(strconv.FormatInt(value, 10)))
+}
+
+//ReadInt64 reads an integer written by WriteInt64 or Increment.
+func (t Transaction) ReadInt64(key string) (int64, error) {
+	data, err := t.ReadRaw(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return 0, &DecodeError{Key: key, Err: err}
+	}
+	return n, nil
+}
+
+//WriteTime writes the given time at the given key in RFC 3339 format, with nanosecond precision.
+func (t Transaction) WriteTime(key string, value time.Time) error {
+	return t.WriteRaw(key, []byte(value.Format(time.RFC3339Nano)))
+}
+
+//ReadTime reads a time written by WriteTime.
+func (t Transaction) ReadTime(key string) (time.Time, error) {
+	data, err := t.ReadRaw(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil {
+		return time.Time{}, &DecodeError{Key: key, Err: err}
+	}
+	return value, nil
+}
+
+//WriteBytes writes a copy of the given bytes at the given key unchanged.
+func (t Transaction) WriteBytes(key string, value []byte) error {
+	return t.WriteRaw(key, append([]byte{}, value...))
+}
+
+//ReadBytes reads a copy of the bytes written by WriteBytes.
+func (t Transaction) ReadBytes(key string) ([]byte, error) {
+	data, err := t.ReadRaw(key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, data...), nil
+}
+
+//Append adds the given bytes to the end of the value stored at the given key, and returns its new length.
+//Missing keys are treated as empty. The bytes are appended with APPEND when the transaction commits,
+//so only the new data is sent rather than the whole value, but the current value is still fetched and watched
+//so that reading the key later in the same transaction includes the appended data.
+//Appended data is stored raw rather than through the Codec, so the result should be read with ReadRaw or ReadString rather than Read.
+func (t Transaction) Append(key string, data []byte) (int64, error) {
+	if err := t.writable(); err != nil {
+		return 0, err
+	}
+	current, err := t.fetch(key)
+	if err != nil && err != ErrNotFound {
+		return 0, err
+	}
+	value := make([]byte, 0, len(current)+len(data))
+	value = append(append(value, current...), data...)
+	if err := checkSize(key, value); err != nil {
+		return 0, err
+	}
+	if _, ok := t.written[key]; ok {
+		t.stage(key, value, t.ttl[key])
+		return int64(len(value)), nil
+	}
+	t.cache[key] = value
+	suffix := append([]byte{}, data...)
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.Append(t.ctx, t.d.key(key), string(suffix))
+	})
+	return int64(len(value)), nil
+}
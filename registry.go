@@ -0,0 +1,39 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+)
+
+//registry holds the databases registered by name.
+var registry = struct {
+	sync.RWMutex
+	m map[string]*Database
+}{m: make(map[string]*Database)}
+
+//Register connects to the redis database at the given url, and makes it available to Use under the given name.
+//It returns an error if the name is already registered. It is safe to call concurrently with Register and Use.
+func Register(name string, url string, opts ...Option) error {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.m[name]; ok {
+		return fmt.Errorf("database: a database is already registered as %q", name)
+	}
+	d, err := NewDatabase(url, opts...)
+	if err != nil {
+		return err
+	}
+	registry.m[name] = d
+	return nil
+}
+
+//Use returns the database registered under the given name.
+func Use(name string) (*Database, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	d, ok := registry.m[name]
+	if !ok {
+		return nil, fmt.Errorf("database: no database is registered as %q", name)
+	}
+	return d, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//scripts caches parsed scripts by their source, so that each is only hashed once.
+var scripts = struct {
+	sync.Mutex
+	m map[string]*redis.Script
+}{m: make(map[string]*redis.Script)}
+
+//RunScript runs the given Lua script on the default database with the given keys and arguments, and returns its result.
+func RunScript(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return db.RunScript(script, keys, args...)
+}
+
+//RunScript is the Database equivalent of the package level RunScript.
+//The script is run with EVALSHA, falling back to EVAL the first time redis sees it.
+//Scripts run outside of any Transaction, and work directly on the stored data without going through the Codec,
+//so keys they use should hold script friendly values, such as those written by Increment, rather than encoded ones.
+//If the Database has a prefix, it is added to each of the keys. A script which returns nil gives a nil result and no error.
+func (d *Database) RunScript(script string, keys []string, args ...interface{}) (interface{}, error) {
+	client, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer d.end(client)
+	scripts.Lock()
+	s, ok := scripts.m[script]
+	if !ok {
+		s = redis.NewScript(script)
+		scripts.m[script] = s
+	}
+	scripts.Unlock()
+	result, err := s.Run(context.Background(), client, d.keys(keys), args...).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return result, err
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+//session records the keys written through a Database created with WithSessionTracking.
+type session struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+//WithSessionTracking makes the Database remember, in memory, every key it writes, so that ClearSessionKeys can delete exactly those.
+//It is intended for integration tests sharing a redis instance, to clean up only the data they created.
+//Keys written by transactions, Atomic, ExecuteChunked, BatchWriter and Import are tracked; keys written with Client, RunScript or by other processes are not.
+//Databases derived with With share the record.
+func WithSessionTracking() Option {
+	return func(d *Database) {
+		d.session = &session{keys: make(map[string]struct{})}
+	}
+}
+
+//track records that the given keys, without their prefix, have been written, if session tracking is enabled.
+func (d *Database) track(keys ...string) {
+	if d == nil || d.session == nil || len(keys) == 0 {
+		return
+	}
+	d.session.mu.Lock()
+	defer d.session.mu.Unlock()
+	for _, k := range keys {
+		d.session.keys[d.key(k)] = struct{}{}
+	}
+}
+
+//SessionKeys returns every key written through the default database since it was connected or last cleared.
+func SessionKeys() []string {
+	return db.SessionKeys()
+}
+
+//SessionKeys is the Database equivalent of the package level SessionKeys.
+//Keys are returned in sorted order as they are stored in redis, including any prefix, and include keys which have since been deleted.
+//It returns nil if the Database was not created with WithSessionTracking.
+func (d *Database) SessionKeys() []string {
+	if d == nil || d.session == nil {
+		return nil
+	}
+	d.session.mu.Lock()
+	defer d.session.mu.Unlock()
+	keys := make([]string, 0, len(d.session.keys))
+	for k := range d.session.keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//ClearSessionKeys deletes every key written through the default database since it was connected or last cleared.
+func ClearSessionKeys() error {
+	return db.ClearSessionKeys()
+}
+
+//ClearSessionKeys is the Database equivalent of the package level ClearSessionKeys.
+//Keys are deleted in batches without a transaction, and forgotten once they have been deleted.
+//It does nothing if the Database was not created with WithSessionTracking.
+func (d *Database) ClearSessionKeys() error {
+	keys := d.SessionKeys()
+	if len(keys) == 0 {
+		return nil
+	}
+	client, err := d.begin()
+	if err != nil {
+		return err
+	}
+	defer d.end(client)
+	ctx := context.Background()
+	for start := 0; start < len(keys); start += flushBatchSize {
+		end := start + flushBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return err
+		}
+		d.session.mu.Lock()
+		for _, k := range keys[start:end] {
+			delete(d.session.keys, k)
+		}
+		d.session.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//encodeMember converts the given value into the form used for set members, which are compared byte for byte by redis.
+//Members are never compressed, so that equal values always have the same form, and cannot be encrypted for the same reason.
+func (d *Database) encodeMember(key string, value interface{}) ([]byte, error) {
+	c := d.encoding()
+	if _, ok := c.(*EncryptingCodec); ok {
+		return nil, fmt.Errorf("%w: key %q", ErrEncryptedMember, key)
+	}
+	data, err := c.Marshal(value)
+	if err != nil {
+		return nil, encodeError(key, c, err)
+	}
+	return data, nil
+}
+
+//SetAdd adds the given member to the set stored at the given key when the transaction commits, creating the set if it does not exist.
+//Members are compared by their encoded form, so types which do not encode deterministically (such as maps with gob) should not be used.
+func (t Transaction) SetAdd(key string, member interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok {
+		return fmt.Errorf("database: cannot add to key %q, which holds an encoded value", key)
+	}
+	data, err := t.d.encodeMember(key, member)
+	if err != nil {
+		return err
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.SAdd(t.ctx, t.d.key(key), data)
+	})
+	return nil
+}
+
+//SetRemove removes the given member from the set stored at the given key when the transaction commits.
+func (t Transaction) SetRemove(key string, member interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	data, err := t.d.encodeMember(key, member)
+	if err != nil {
+		return err
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.SRem(t.ctx, t.d.key(key), data)
+	})
+	return nil
+}
+
+//SetContains reports whether the given member is in the set stored at the given key.
+//The set is watched, so the transaction is retried if another process changes it before commit,
+//but it is read from the database, so changes staged earlier in the same transaction are not included.
+func (t Transaction) SetContains(key string, member interface{}) (bool, error) {
+	if err := t.usable(); err != nil {
+		return false, err
+	}
+	data, err := t.d.encodeMember(key, member)
+	if err != nil {
+		return false, err
+	}
+	if err := t.watch(key); err != nil {
+		return false, err
+	}
+	t.markRead(key)
+	return t.tx.SIsMember(t.ctx, t.d.key(key), data).Result()
+}
+
+//SetMembers reads every member of the set stored at the given key into out, which must be a pointer to a slice
+//or an error is returned and out is left untouched. The members are in no particular order, and a missing key reads as an empty set.
+//The set is watched, but changes staged earlier in the same transaction are not included.
+func (t Transaction) SetMembers(key string, out interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	members, err := t.tx.SMembers(t.ctx, t.d.key(key)).Result()
+	if err != nil {
+		return err
+	}
+	return t.d.decodeSlice(key, members, out)
+}
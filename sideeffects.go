@@ -0,0 +1,70 @@
+package database
+
+import (
+	"reflect"
+	"time"
+)
+
+var detectSideEffects bool
+
+//SetDetectSideEffects makes Execute compare what each attempt of a transaction would commit with the attempt before it,
+//and log a warning if two attempts which read the same values would commit different changes.
+//Since the function is given the same inputs, that usually means it depends on state outside the transaction,
+//such as a slice it appends to or a counter it increments, which is changed again every time it is retried.
+//Attempts which read different values are not compared, as their changes may rightly differ, so not every side effect is found.
+//Only the values read with Read, ReadMulti, Exists and the other methods which fetch whole values are recorded, so attempts which
+//read anything else, such as a hash field, a list, a set or a TTL, are not compared either.
+//It is meant for debugging, because it holds on to what each attempt read and staged until the transaction ends.
+//It should be called before any transactions are executed.
+func SetDetectSideEffects(enabled bool) {
+	detectSideEffects = enabled
+}
+
+//writeSet records what one attempt of a transaction read and what it would have committed.
+type writeSet struct {
+	//complete is set if the value of every key the attempt read is in inputs, so that attempts can be compared.
+	complete bool
+	inputs   map[string][]byte
+	writes   map[string][]byte
+	ttl      map[string]time.Duration
+	deleted  map[string]struct{}
+	//queued holds the number of commands queued for each key, since the commands themselves cannot be compared.
+	queued map[string]int
+}
+
+//writeSet returns the writeSet of the Transaction, which should not be used afterwards.
+func (t Transaction) writeSet() *writeSet {
+	w := &writeSet{
+		complete: true,
+		inputs:   t.seen,
+		writes:   make(map[string][]byte, len(t.written)),
+		ttl:      make(map[string]time.Duration, len(t.written)),
+		deleted:  t.deleted,
+		queued:   make(map[string]int, len(t.queued)),
+	}
+	for k := range t.read {
+		if _, ok := t.seen[k]; !ok {
+			w.complete = false
+		}
+	}
+	for k := range t.written {
+		w.writes[k] = t.cache[k]
+		w.ttl[k] = t.ttl[k]
+	}
+	for k, commands := range t.queued {
+		w.queued[k] = len(commands)
+	}
+	return w
+}
+
+//sideEffect reports whether the writeSet read the same values as the previous one, but would commit different changes.
+//It is false unless the values of every key read by both are known.
+func (w *writeSet) sideEffect(previous *writeSet) bool {
+	if previous == nil || !w.complete || !previous.complete || !reflect.DeepEqual(w.inputs, previous.inputs) {
+		return false
+	}
+	return !reflect.DeepEqual(w.writes, previous.writes) ||
+		!reflect.DeepEqual(w.ttl, previous.ttl) ||
+		!reflect.DeepEqual(w.deleted, previous.deleted) ||
+		!reflect.DeepEqual(w.queued, previous.queued)
+}
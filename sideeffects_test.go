@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+//attempt builds the writeSet of an attempt which read the given keys, recording the values of those in seen,
+//and wrote out with the given value.
+func attempt(read []string, seen map[string][]byte, out string) *writeSet {
+	var d *Database
+	t := d.newTransaction(context.Background(), nil)
+	t.markRead(read...)
+	for k, v := range seen {
+		t.seen[k] = v
+	}
+	t.stage("out", []byte(out), 0)
+	return t.writeSet()
+}
+
+func TestSideEffectSameInputs(t *testing.T) {
+	seen := map[string][]byte{"k": []byte("1")}
+	first := attempt([]string{"k"}, seen, "a")
+	if second := attempt([]string{"k"}, seen, "a"); second.sideEffect(first) {
+		t.Fatal("identical attempts reported as a side effect")
+	}
+	if second := attempt([]string{"k"}, seen, "b"); !second.sideEffect(first) {
+		t.Fatal("different changes after the same reads not reported")
+	}
+}
+
+func TestSideEffectDifferentInputs(t *testing.T) {
+	first := attempt([]string{"k"}, map[string][]byte{"k": []byte("1")}, "a")
+	second := attempt([]string{"k"}, map[string][]byte{"k": []byte("2")}, "b")
+	if second.sideEffect(first) {
+		t.Fatal("different changes after different reads reported as a side effect")
+	}
+}
+
+//TestSideEffectUnrecordedRead checks that attempts which read a key without recording its value, as HRead does,
+//are not compared, since a conflict on that key may rightly change what they write.
+func TestSideEffectUnrecordedRead(t *testing.T) {
+	first := attempt([]string{"h"}, nil, "1")
+	second := attempt([]string{"h"}, nil, "2")
+	if second.sideEffect(first) {
+		t.Fatal("attempts with unrecorded reads reported as a side effect")
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+//Stats describes the health of the connection to a database, for reporting from a health or debug endpoint.
+type Stats struct {
+	//Hits is the number of times a free connection was found in the pool.
+	Hits uint32 `json:"hits"`
+	//Misses is the number of times a free connection was not found in the pool.
+	Misses uint32 `json:"misses"`
+	//Timeouts is the number of times waiting for a connection timed out, which suggests the pool is saturated.
+	Timeouts uint32 `json:"timeouts"`
+	//TotalConns is the number of connections in the pool.
+	TotalConns uint32 `json:"total_conns"`
+	//IdleConns is the number of idle connections in the pool.
+	IdleConns uint32 `json:"idle_conns"`
+	//StaleConns is the number of stale connections removed from the pool.
+	StaleConns uint32 `json:"stale_conns"`
+	//PingLatency is how long a PING took to be answered, in nanoseconds when encoded as JSON.
+	PingLatency time.Duration `json:"ping_latency"`
+	//MaxRetries is the number of times Execute attempts a transaction, as set by SetMaxRetries.
+	MaxRetries int `json:"max_retries"`
+}
+
+//GetStats returns the Stats of the default database.
+func GetStats() (Stats, error) {
+	return db.Stats()
+}
+
+//Stats returns the connection pool statistics of the Database, along with the latency of a PING sent to measure it.
+//The pool statistics count from when the current connection was made, so they restart if the Database reconnects.
+//If the PING fails, the statistics are still returned along with its error.
+func (d *Database) Stats() (Stats, error) {
+	client, err := d.begin()
+	if err != nil {
+		return Stats{}, err
+	}
+	defer d.end(client)
+	pool := client.PoolStats()
+	stats := Stats{
+		Hits:       pool.Hits,
+		Misses:     pool.Misses,
+		Timeouts:   pool.Timeouts,
+		TotalConns: pool.TotalConns,
+		IdleConns:  pool.IdleConns,
+		StaleConns: pool.StaleConns,
+		MaxRetries: maxDatabaseRetryAttempts,
+	}
+	start := time.Now()
+	err = client.Ping(context.Background()).Err()
+	stats.PingLatency = time.Since(start)
+	return stats, err
+}
@@ -0,0 +1,30 @@
+package database
+
+//Tx is the set of Transaction operations needed by code which only reads, writes and deletes keys.
+//Transaction implements it, as does the in-memory fake in the dbtest package.
+type Tx interface {
+	Exists(key string) bool
+	Read(key string, value interface{}) error
+	Write(key string, value interface{}) error
+	Delete(key string) error
+}
+
+//Store executes transactions using the Tx interface.
+//*Database implements it, as does the in-memory fake in the dbtest package,
+//so code written against a Store can be unit tested without a redis server:
+//pass Default() (or any other *Database) in production, and dbtest.New() in tests.
+type Store interface {
+	Transact(f func(t Tx) error) error
+}
+
+//Default returns the default Database used by the package level functions, or nil if it is not connected.
+func Default() *Database {
+	return db
+}
+
+//Transact is like Execute, but passes the Transaction to f as a Tx.
+func (d *Database) Transact(f func(t Tx) error) error {
+	return d.Execute(func(t Transaction) error {
+		return f(t)
+	})
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//StreamEntry is an entry read from a stream by StreamRead.
+type StreamEntry struct {
+	//ID is the id of the entry, which can be passed to StreamRead to read the entries after it.
+	ID string
+	//Values holds the encoded value of each field, which can be decoded with Decode.
+	Values map[string][]byte
+
+	d   *Database
+	key string
+}
+
+//Decode decodes the given field of the entry into the given interface, which should be a pointer.
+//It returns ErrNotFound if the entry has no such field, and an error if the entry was not returned by StreamRead.
+func (e StreamEntry) Decode(field string, value interface{}) error {
+	if e.d == nil {
+		return errors.New("database: stream entry was not returned by StreamRead")
+	}
+	data, ok := e.Values[field]
+	if !ok {
+		return ErrNotFound
+	}
+	return e.d.decode(e.key, data, value)
+}
+
+//StreamAdd appends an entry with the given fields to the stream stored at the given key in the default database, and returns its id.
+func StreamAdd(key string, fields map[string]interface{}) (id string, err error) {
+	return db.StreamAdd(key, fields)
+}
+
+//StreamAdd is the Database equivalent of the package level StreamAdd.
+//Each field value is encoded with the Codec, and the id is generated by redis, so entries are ordered by the time they were added.
+//Streams are append only and are not part of any Transaction; the entry is added immediately with XADD.
+func (d *Database) StreamAdd(key string, fields map[string]interface{}) (id string, err error) {
+	client, err := d.begin()
+	if err != nil {
+		return "", err
+	}
+	defer d.end(client)
+	values := make(map[string]interface{}, len(fields))
+	for field, v := range fields {
+		data, err := d.encode(key, v)
+		if err != nil {
+			return "", err
+		}
+		values[field] = data
+	}
+	return client.XAdd(context.Background(), &redis.XAddArgs{Stream: d.key(key), Values: values}).Result()
+}
+
+//StreamRead returns up to count entries from the stream stored at the given key in the default database, starting after lastID.
+func StreamRead(key string, lastID string, count int64) ([]StreamEntry, error) {
+	return db.StreamRead(key, lastID, count)
+}
+
+//StreamRead is the Database equivalent of the package level StreamRead.
+//Pass "0" as lastID to read from the start of the stream, then the ID of the last entry returned to read the next ones,
+//which makes the stream replayable from any point. A count of zero or less reads every remaining entry.
+//It does not wait for new entries, and returns none if there are no entries after lastID or the stream does not exist.
+func (d *Database) StreamRead(key string, lastID string, count int64) ([]StreamEntry, error) {
+	client, err := d.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer d.end(client)
+	args := &redis.XReadArgs{Streams: []string{d.key(key), lastID}, Block: -1}
+	if count > 0 {
+		args.Count = count
+	}
+	streams, err := client.XRead(context.Background(), args).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []StreamEntry
+	for _, s := range streams {
+		for _, m := range s.Messages {
+			e := StreamEntry{ID: m.ID, Values: make(map[string][]byte, len(m.Values)), d: d, key: key}
+			for field, v := range m.Values {
+				if data, ok := v.(string); ok {
+					e.Values[field] = []byte(data)
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//versionedValue is a value stored by WriteVersioned, along with its version.
+type versionedValue struct {
+	data    []byte
+	version int64
+}
+
+//ReadVersioned reads a value written by WriteVersioned into the given interface, which should be a pointer, and returns its version.
+//The key is watched, like Read.
+func (t Transaction) ReadVersioned(key string, value interface{}) (version int64, err error) {
+	if err := t.usable(); err != nil {
+		return 0, err
+	}
+	v, err := t.fetchVersioned(key)
+	if err != nil {
+		return 0, err
+	}
+	return v.version, t.d.decode(key, v.data, value)
+}
+
+//WriteVersioned writes the given value at the given key and returns its new version, which is one more than the version it replaces.
+//The value and its version are stored together in a hash with the fields "value" and "version", so a versioned key should
+//only be used with ReadVersioned, WriteVersioned, WriteIfVersion and Delete. Versions start at one, and start again after a Delete.
+//It returns an error for a key which holds a plain value in the transaction, such as one staged by Write, Increment or Append; Delete it first.
+//Versions survive between transactions, so a version returned to a client can later be passed to WriteIfVersion to detect lost updates.
+func (t Transaction) WriteVersioned(key string, value interface{}) (version int64, err error) {
+	version, _, err = t.writeVersioned(key, value, -1)
+	return version, err
+}
+
+//WriteIfVersion writes the given value like WriteVersioned, but only if the stored version is still the expected one,
+//and reports whether it did. An expected version of zero means the key must not exist.
+//If the stored version differs, nothing is written and the current version is returned.
+func (t Transaction) WriteIfVersion(key string, value interface{}, expected int64) (version int64, written bool, err error) {
+	return t.writeVersioned(key, value, expected)
+}
+
+//writeVersioned stages a versioned write, if the stored version matches expected or expected is negative.
+func (t Transaction) writeVersioned(key string, value interface{}, expected int64) (int64, bool, error) {
+	if err := t.writable(); err != nil {
+		return 0, false, err
+	}
+	//A plain value staged by Write, Increment or Append would make the HSET fail when the transaction commits,
+	//after the rest of it had been applied.
+	if _, ok := t.cache[key]; ok {
+		return 0, false, fmt.Errorf("database: cannot write a versioned value to key %q, which holds a plain value", key)
+	}
+	current, err := t.fetchVersioned(key)
+	if err != nil && err != ErrNotFound {
+		return 0, false, err
+	}
+	if expected >= 0 && current.version != expected {
+		return current.version, false, nil
+	}
+	data, err := t.d.encode(key, value)
+	if err != nil {
+		return 0, false, err
+	}
+	next := versionedValue{data: data, version: current.version + 1}
+	if _, ok := t.deleted[key]; ok {
+		delete(t.deleted, key)
+		t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+			pipe.Del(t.ctx, t.d.key(key))
+		})
+	}
+	t.versioned[key] = next
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.HSet(t.ctx, t.d.key(key), "value", next.data, "version", next.version)
+	})
+	return next.version, true, nil
+}
+
+//fetchVersioned returns the versioned value at the given key, watching it and caching it for the rest of the transaction.
+func (t Transaction) fetchVersioned(key string) (versionedValue, error) {
+	if v, ok := t.versioned[key]; ok {
+		return v, nil
+	}
+	if _, ok := t.deleted[key]; ok {
+		return versionedValue{}, ErrNotFound
+	}
+	if err := t.watch(key); err != nil {
+		return versionedValue{}, err
+	}
+	t.markRead(key)
+	fields, err := t.tx.HMGet(t.ctx, t.d.key(key), "value", "version").Result()
+	if err != nil {
+		return versionedValue{}, err
+	}
+	data, ok := fields[0].(string)
+	if !ok {
+		return versionedValue{}, ErrNotFound
+	}
+	stamp, _ := fields[1].(string)
+	version, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return versionedValue{}, fmt.Errorf("database: invalid version at key %q: %v", key, err)
+	}
+	v := versionedValue{data: []byte(data), version: version}
+	t.versioned[key] = v
+	return v, nil
+}
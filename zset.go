@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//ZAdd adds the given member to the sorted set stored at the given key with the given score when the transaction commits,
+//creating the set if it does not exist. If the member is already present, its score is replaced.
+//As with SetAdd, members are compared by their encoded form.
+func (t Transaction) ZAdd(key string, score float64, member interface{}) error {
+	if err := t.writable(); err != nil {
+		return err
+	}
+	if _, ok := t.written[key]; ok {
+		return fmt.Errorf("database: cannot add to key %q, which holds an encoded value", key)
+	}
+	data, err := t.d.encodeMember(key, member)
+	if err != nil {
+		return err
+	}
+	t.queued[key] = append(t.queued[key], func(pipe redis.Pipeliner) {
+		pipe.ZAdd(t.ctx, t.d.key(key), redis.Z{Score: score, Member: data})
+	})
+	return nil
+}
+
+//ZRange reads the members of the sorted set stored at the given key from rank start to rank stop into out, which should be a pointer to a slice.
+//Members are ordered from the lowest score to the highest, with equal scores ordered by their encoded form.
+//Both start and stop are inclusive, and negative ranks count back from the highest score, so ZRange(key, 0, -1, &out) reads the whole set.
+//The set is watched, but changes staged earlier in the same transaction are not included.
+func (t Transaction) ZRange(key string, start, stop int64, out interface{}) error {
+	if err := t.usable(); err != nil {
+		return err
+	}
+	if err := t.watch(key); err != nil {
+		return err
+	}
+	t.markRead(key)
+	members, err := t.tx.ZRange(t.ctx, t.d.key(key), start, stop).Result()
+	if err != nil {
+		return err
+	}
+	return t.d.decodeSlice(key, members, out)
+}
+
+//ZScore returns the score of the given member in the sorted set stored at the given key, or ErrNotFound if it is not a member.
+//The set is watched, but changes staged earlier in the same transaction are not included.
+func (t Transaction) ZScore(key string, member interface{}) (float64, error) {
+	if err := t.usable(); err != nil {
+		return 0, err
+	}
+	data, err := t.d.encodeMember(key, member)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.watch(key); err != nil {
+		return 0, err
+	}
+	t.markRead(key)
+	score, err := t.tx.ZScore(t.ctx, t.d.key(key), string(data)).Result()
+	if err == redis.Nil {
+		return 0, ErrNotFound
+	}
+	return score, err
+}